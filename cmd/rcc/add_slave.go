@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+
+	"github.com/kizkoh/rcc/rcc"
+	"github.com/pkg/errors"
+)
+
+func runAddSlave(ctx context.Context, g *Global, args []string) error {
+	var planFile = ""
+	var help = false
+
+	// parse args
+	flags := flag.NewFlagSet("add-slave", flag.ContinueOnError)
+
+	flags.StringVar(&planFile, "plan", planFile, "plan")
+	g.SetFlags(flags)
+	flags.BoolVar(&help, "h", help, "help")
+	flags.BoolVar(&help, "help", help, "help")
+
+	flags.Usage = func() { addSlaveUsage() }
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if help {
+		addSlaveUsage()
+		return nil
+	}
+
+	if err := g.InitLogger(); err != nil {
+		return err
+	}
+
+	args = flags.Args()
+	var master string
+	var slave string
+	if len(args) != 2 {
+		addSlaveUsage()
+		return nil
+	} else {
+		master = args[len(args)-1]
+	}
+	slave = args[len(args)-2]
+
+	manager, err := g.Manager()
+	if err != nil {
+		return err
+	}
+	masterAddr, err := g.nodeAddr(ctx, master)
+	if err != nil {
+		return err
+	}
+	slaveAddr, err := g.nodeAddr(ctx, slave)
+	if err != nil {
+		return err
+	}
+
+	masterClient := manager.Client(masterAddr)
+	cluster, err := rcc.ClusterNodesContext(ctx, masterClient)
+	if err != nil {
+		return err
+	}
+
+	var myself rcc.ClusterNode
+	for _, node := range cluster {
+		for _, flag := range node.Flags {
+			if flag == "myself" {
+				myself = node
+			}
+		}
+	}
+	if myself.ID == "" {
+		return errors.Wrap(rcc.ErrMyselfNotFound, fmt.Sprintf("%v-%v failed: %s", App.Name, App.Version, masterAddr))
+	}
+	masterID := myself.ID
+	masterIP := myself.IP
+	masterPort := fmt.Sprintf("%d", myself.Port)
+	if masterIP == "" {
+		// node not meeting other nodes yet does not know its own IP, CLUSTER MEET requires IP address
+		masterIP, _, _ = net.SplitHostPort(masterAddr)
+	}
+
+	// ToDo: Assert new slave node is cluster
+	// Assert new slave node is empty
+	if err := rcc.AssertEmptyNodeContext(ctx, manager.Client(slaveAddr)); err != nil {
+		return err
+	}
+
+	plan := rcc.NewPlan(g.CommandLine(), masterAddr)
+	epoch := myself.ConfigEpoch
+	plan.Require(rcc.Precondition{Type: rcc.PreconditionNode, ID: masterID, Role: "master", ConfigEpoch: &epoch})
+	plan.Require(rcc.Precondition{Type: rcc.PreconditionEmpty, Addr: slaveAddr})
+	plan.AddCommand(slaveAddr, "cluster", "meet", masterIP, masterPort)
+	plan.Add(rcc.Step{Type: rcc.StepWaitJoin, Node: slaveAddr, ID: masterID})
+	plan.AddCommand(slaveAddr, "cluster", "replicate", masterID)
+
+	if planFile != "" {
+		if err := plan.Write(planFile); err != nil {
+			return err
+		}
+		fmt.Printf("plan is written to %s, run '%s apply %s' to add node\n", planFile, App.Name, planFile)
+		return nil
+	}
+	return g.audit(ctx, masterAddr, func() error {
+		fmt.Printf("configure node as replica of %s\n", master)
+		if err := plan.Apply(ctx, manager); err != nil {
+			return err
+		}
+		fmt.Print("new nodes added correctly\n")
+		return nil
+	})
+}
+
+func addSlaveUsage() {
+	helpText := `
+usage:
+   {{.Name}} [command options] <SLAVE HOST:PORT> <MASTER HOST:PORT>
+
+version:
+   {{.Version}}
+
+author:
+   kizkoh<GitHub: https://github.com/kizkoh>
+
+options:
+   --plan <FILE>                                Write plan to file instead of adding node, run it by apply
+   --help, -h                                   Show help
+
+global options:
+{{.GlobalOptions}}
+`
+	printUsage(App.Name+" add-slave", helpText)
+}
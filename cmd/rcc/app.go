@@ -11,6 +11,6 @@ type app struct {
 
 // App include application name and version
 var App = app{
-	Name:    "rcc-tree",
+	Name:    "rcc",
 	Version: rcc.App.Version,
 }
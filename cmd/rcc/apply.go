@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/kizkoh/rcc/rcc"
+)
+
+func runApply(ctx context.Context, g *Global, args []string) error {
+	var check = false
+	var help = false
+
+	// parse args
+	flags := flag.NewFlagSet("apply", flag.ContinueOnError)
+
+	flags.BoolVar(&check, "check", check, "check")
+	g.SetFlags(flags)
+	flags.BoolVar(&help, "h", help, "help")
+	flags.BoolVar(&help, "help", help, "help")
+
+	flags.Usage = func() { applyUsage() }
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if help || flags.NArg() != 1 {
+		applyUsage()
+		return nil
+	}
+
+	if err := g.InitLogger(); err != nil {
+		return err
+	}
+
+	plan, err := rcc.ReadPlan(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	manager, err := g.Manager()
+	if err != nil {
+		return err
+	}
+
+	if check {
+		if err := plan.Check(ctx, manager); err != nil {
+			return err
+		}
+		fmt.Printf("preconditions of %d steps planned by '%s' are met\n", len(plan.Steps), plan.Command)
+		return nil
+	}
+	return g.audit(ctx, plan.Cluster, func() error {
+		if err := plan.Apply(ctx, manager); err != nil {
+			return err
+		}
+		fmt.Printf("%d steps planned by '%s' are applied\n", len(plan.Steps), plan.Command)
+		return nil
+	})
+}
+
+func applyUsage() {
+	helpText := `
+usage:
+   {{.Name}} [command options] <PLAN FILE>
+
+version:
+   {{.Version}}
+
+author:
+   kizkoh<GitHub: https://github.com/kizkoh>
+
+options:
+   --check                                      Check preconditions against live topology without running steps
+   --help, -h                                   Show help
+
+global options:
+{{.GlobalOptions}}
+`
+	printUsage(App.Name+" apply", helpText)
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/kizkoh/rcc/rcc"
+)
+
+func runCountKeySlot(ctx context.Context, g *Global, args []string) error {
+	var (
+		rank    = 0
+		cluster = false
+		count   = rcc.DefaultCountOptions
+		asJSON  = false
+		help    = false
+	)
+
+	// parse args
+	flags := flag.NewFlagSet("count-key-slot", flag.ContinueOnError)
+
+	flags.IntVar(&rank, "rank", rank, "rank")
+	flags.BoolVar(&cluster, "cluster", cluster, "cluster")
+	flags.IntVar(&count.Batch, "pipeline", count.Batch, "pipeline")
+	flags.IntVar(&count.Workers, "parallel", count.Workers, "parallel")
+	flags.BoolVar(&asJSON, "json", asJSON, "json")
+	g.SetFlags(flags)
+	flags.BoolVar(&help, "h", help, "help")
+	flags.BoolVar(&help, "help", help, "help")
+
+	flags.Usage = func() { countKeySlotUsage() }
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if help {
+		countKeySlotUsage()
+		return nil
+	}
+
+	if err := g.InitLogger(); err != nil {
+		return err
+	}
+
+	args = flags.Args()
+	if len(args) > 1 {
+		countKeySlotUsage()
+		return nil
+	}
+
+	nodes, err := g.clusterNodes(ctx, "", args)
+	if err != nil {
+		return err
+	}
+	manager, err := g.Manager()
+	if err != nil {
+		return err
+	}
+
+	if cluster {
+		stats, err := rcc.CollectStats(ctx, manager, nodes, count)
+		if asJSON {
+			if jerr := json.NewEncoder(os.Stdout).Encode(stats); jerr != nil && err == nil {
+				err = jerr
+			}
+			return err
+		}
+		for _, shard := range stats.Shards {
+			printShardStats(shard, shard.NodeStats, rank)
+		}
+		printBalance(stats)
+		// interrupted counts are printed partially
+		return err
+	}
+
+	// only shard of myself is counted without --cluster, keys and memory are of myself
+	for _, node := range nodes {
+		if !node.HasFlag("myself") {
+			continue
+		}
+		master := GetMasterNode(nodes, node)
+		counts, err := rcc.CountKeysInSlots(ctx, manager, []rcc.ClusterNode{master}, count)
+		if err != nil && ctx.Err() == nil {
+			return err
+		}
+		shard := rcc.NewShardStats(master, counts)
+		shard.ID, shard.Host, shard.Flags = node.ID, node.Host, node.Flags
+		shard.Addr = node.Addr()
+		nodeStats, err := rcc.CollectNodeStats(ctx, manager.NodeClient(node))
+		if err != nil {
+			return err
+		}
+		printShardStats(shard, nodeStats, rank)
+	}
+	return ctx.Err()
+}
+
+// printShardStats print keys and memory of node, and top rank slots of shard
+func printShardStats(shard rcc.ShardStats, node rcc.NodeStats, rank int) {
+	_, port, _ := net.SplitHostPort(shard.Addr)
+	fmt.Printf("%s %s:%s ", shard.ID, shard.Host, port)
+	fmt.Printf("%-16s", "["+strings.Join(shard.Flags, ",")+"]")
+	var avg uint64
+	if shard.Slots > 0 {
+		avg = node.Keys / uint64(shard.Slots)
+	}
+	fmt.Printf("slots:%5d count:%8d avg:%5d ", shard.Slots, node.Keys, avg)
+	fmt.Printf("used_memory:%12d", node.UsedMemory)
+	fmt.Print("\n")
+	for i, slot := range shard.Ranking() {
+		if i >= rank {
+			break
+		}
+		fmt.Println(slot)
+	}
+}
+
+// printBalance print spread of slots, keys and memory across masters, and move reducing imbalance of keys the most
+func printBalance(stats *rcc.ClusterStats) {
+	if len(stats.Shards) == 0 {
+		return
+	}
+	addrs := make(map[string]string)
+	for _, shard := range stats.Shards {
+		addrs[shard.ID] = shard.Addr
+	}
+	balance := stats.Balance
+
+	fmt.Print("\n")
+	fmt.Printf("%-12s %14s %14s %14s %14s %8s\n", "balance", "mean", "stddev", "min", "max", "max/min")
+	for _, s := range []struct {
+		name   string
+		spread rcc.Spread
+	}{
+		{"slots", balance.Slots},
+		{"keys", balance.Keys},
+		{"used_memory", balance.UsedMemory},
+	} {
+		ratio := "-"
+		if s.spread.Ratio > 0 {
+			ratio = fmt.Sprintf("%.2f", s.spread.Ratio)
+		}
+		fmt.Printf("%-12s %14.1f %14.1f %14.0f %14.0f %8s\n", s.name, s.spread.Mean, s.spread.StdDev, s.spread.Min, s.spread.Max, ratio)
+	}
+	fmt.Printf("overloaded:  %s %s keys:%.0f\n", balance.Overloaded, addrs[balance.Overloaded], balance.Keys.Max)
+	fmt.Printf("underloaded: %s %s keys:%.0f\n", balance.Underloaded, addrs[balance.Underloaded], balance.Keys.Min)
+	if move := balance.Move; move != nil {
+		fmt.Printf("best move:   slots %s (keys:%d) from %s to %s, keys stddev %.1f -> %.1f\n",
+			move.Slots, move.Keys, move.From.Addr, move.To.Addr, balance.Keys.StdDev, move.StdDevAfter)
+	} else {
+		fmt.Print("best move:   none reduces imbalance of keys\n")
+	}
+}
+
+func GetMasterNode(nodes []rcc.ClusterNode, node rcc.ClusterNode) (master rcc.ClusterNode) {
+	var masterNodeID = ""
+	master = node
+	// ToDo: check node healthcheck
+	for _, flag := range node.Flags {
+		if flag == "slave" {
+			masterNodeID = node.SlaveOf
+			break
+		}
+	}
+	for _, node = range nodes {
+		if node.ID == masterNodeID {
+			master = node
+			break
+		}
+	}
+	return master
+}
+
+func countKeySlotUsage() {
+	helpText := `
+usage:
+   {{.Name}} [command options] [<HOST:PORT>[,<HOST:PORT>...]]
+
+version:
+   {{.Version}}
+
+author:
+   kizkoh<GitHub: https://github.com/kizkoh>
+
+options:
+   --rank                                       Print rank of slot capacity
+   --cluster                                    Print cluster information and balance of slots, keys and memory across masters
+   --pipeline <N>                               Slots counted in one round trip (default: 1000)
+   --parallel <N>                               Shards counted concurrently (default: 8)
+   --json                                       Print statistics of every slot and shard as JSON with --cluster
+   --help, -h                                   Show help
+
+global options:
+{{.GlobalOptions}}
+`
+	printUsage(App.Name+" count-key-slot", helpText)
+}
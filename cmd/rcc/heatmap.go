@@ -0,0 +1,391 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/kizkoh/rcc/rcc"
+	"github.com/pkg/errors"
+)
+
+// heatmap cell marks
+const (
+	uncoveredMark = 'x'
+	openMark      = '!'
+	sharedMark    = '#' // master beyond ownerSymbols
+	sharedSplit   = '+' // cell split between masters of which major one is marked sharedMark
+)
+
+// ownerSymbols is marks of masters, only letters are used so that split cell is told by lower case,
+// X is left out not to be confused with uncovered mark in lower case
+const ownerSymbols = "ABCDEFGHIJKLMNOPQRSTUVWYZ"
+
+// keysRamp is marks of key count per cell from none to most keys
+const keysRamp = " .:-=+*#%@"
+
+// ANSI colors of heatmap cell
+var (
+	ownerColors   = []int{33, 40, 214, 165, 45, 226, 202, 99, 118, 207, 39, 172}
+	keysColors    = []int{240, 24, 31, 37, 43, 112, 184, 214, 202, 196}
+	uncoveredAttr = "\x1b[97;41m"
+	openAttr      = "\x1b[30;43m"
+)
+
+func runHeatmap(ctx context.Context, g *Global, args []string) error {
+	var (
+		by    = "owner"
+		width = 64
+		cell  = 16
+		color = "auto"
+		count = rcc.DefaultCountOptions
+		help  = false
+	)
+
+	// parse args
+	flags := flag.NewFlagSet("heatmap", flag.ContinueOnError)
+
+	flags.StringVar(&by, "by", by, "by")
+	flags.IntVar(&width, "width", width, "width")
+	flags.IntVar(&cell, "cell", cell, "cell")
+	flags.StringVar(&color, "color", color, "color")
+	flags.IntVar(&count.Batch, "pipeline", count.Batch, "pipeline")
+	flags.IntVar(&count.Workers, "parallel", count.Workers, "parallel")
+	g.SetFlags(flags)
+	flags.BoolVar(&help, "h", help, "help")
+	flags.BoolVar(&help, "help", help, "help")
+
+	flags.Usage = func() { heatmapUsage() }
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if help || flags.NArg() > 1 {
+		heatmapUsage()
+		return nil
+	}
+
+	if err := g.InitLogger(); err != nil {
+		return err
+	}
+
+	if by != "owner" && by != "keys" {
+		err := errors.New(fmt.Sprintf("Unknown --by %q, owner or keys is expected", by))
+		return errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+	}
+	if width < 1 || cell < 1 || cell > rcc.SlotCount {
+		err := errors.New("--width and --cell must be positive, --cell must not exceed number of slots")
+		return errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+	}
+	colored, err := useColor(color)
+	if err != nil {
+		return err
+	}
+
+	nodes, err := g.clusterNodes(ctx, "", flags.Args())
+	if err != nil {
+		return err
+	}
+	manager, err := g.Manager()
+	if err != nil {
+		return err
+	}
+
+	h := &heatmap{
+		cell:    cell,
+		width:   width,
+		colored: colored,
+		owners:  rcc.SlotOwners(nodes),
+		nodes:   nodes,
+	}
+	// open slots of unreachable master are not shown
+	h.open, err = rcc.OpenSlots(ctx, manager, nodes)
+	if err != nil {
+		rcc.DefaultLogger.Warn("open slots are partially read", "error", err)
+	}
+	if by == "keys" {
+		// uncounted slots are drawn as uncovered
+		h.keys, err = rcc.CountKeysInSlots(ctx, manager, nodes, count)
+		if err != nil && ctx.Err() != nil {
+			return err
+		}
+		if err != nil {
+			rcc.DefaultLogger.Warn("keys are partially counted", "error", err)
+		}
+	}
+	h.Render(os.Stdout)
+	return nil
+}
+
+// heatmap is slot space drawn as grid of cells, each cell is cell slots in a row
+type heatmap struct {
+	cell    int
+	width   int
+	colored bool
+	nodes   []rcc.ClusterNode
+	owners  []string
+	open    map[int][]rcc.Slot
+	keys    []int64 // keys per slot, nil to color by owner
+
+	order map[string]int // index of master in order of first slot, set by Render
+}
+
+// Render writes grid and legend
+func (h *heatmap) Render(w io.Writer) {
+	marks := h.ownerMarks()
+	h.order = make(map[string]int)
+	for i, id := range orderedOwners(h.owners) {
+		h.order[id] = i
+	}
+	cells := (rcc.SlotCount + h.cell - 1) / h.cell
+
+	// cells are scaled by cell of most keys so that hotspot stands out
+	var max int64
+	for c := 0; c < cells && h.keys != nil; c++ {
+		if sum, _ := h.cellKeys(c); sum > max {
+			max = sum
+		}
+	}
+
+	mode := "owner"
+	if h.keys != nil {
+		mode = "keys"
+	}
+	fmt.Fprintf(w, "slots 0-%d, %d slots per cell, by %s\n", rcc.SlotCount-1, h.cell, mode)
+	for row := 0; row*h.width < cells; row++ {
+		fmt.Fprintf(w, "%5d ", row*h.width*h.cell)
+		for c := row * h.width; c < (row+1)*h.width && c < cells; c++ {
+			mark, attr := h.cellMark(c, marks, max)
+			if h.colored && attr != "" {
+				fmt.Fprintf(w, "%s%c\x1b[0m", attr, mark)
+			} else {
+				fmt.Fprintf(w, "%c", mark)
+			}
+		}
+		fmt.Fprint(w, "\n")
+	}
+	h.legend(w, marks, max)
+}
+
+// cellMark returns mark and color of cell, uncovered slot is prior to open slot, open slot to others
+func (h *heatmap) cellMark(c int, marks map[string]rune, max int64) (rune, string) {
+	start, end := h.cellRange(c)
+	uncovered, open := false, false
+	owned := make(map[string]int)
+	for slot := start; slot <= end; slot++ {
+		if h.owners[slot] == "" {
+			uncovered = true
+		}
+		if len(h.open[slot]) > 0 {
+			open = true
+		}
+		owned[h.owners[slot]]++
+	}
+	switch {
+	case uncovered:
+		return uncoveredMark, uncoveredAttr
+	case open:
+		return openMark, openAttr
+	}
+
+	if h.keys != nil {
+		sum, counted := h.cellKeys(c)
+		if !counted {
+			return uncoveredMark, uncoveredAttr
+		}
+		level := 0
+		if sum > 0 && max > 0 {
+			level = 1 + int(sum*int64(len(keysRamp)-2)/max)
+			if level >= len(keysRamp) {
+				level = len(keysRamp) - 1
+			}
+		}
+		return rune(keysRamp[level]), fmt.Sprintf("\x1b[38;5;%dm", keysColors[level])
+	}
+
+	// cell split between masters is lower case of its major owner to show fragmentation
+	var major string
+	for id, n := range owned {
+		if n > owned[major] || (n == owned[major] && id < major) {
+			major = id
+		}
+	}
+	mark := marks[major]
+	if len(owned) > 1 {
+		mark = splitMark(mark)
+	}
+	return mark, h.ownerAttr(major)
+}
+
+// splitMark returns mark of cell split between masters of which major one is marked mark
+func splitMark(mark rune) rune {
+	if mark == sharedMark {
+		return sharedSplit
+	}
+	return []rune(strings.ToLower(string(mark)))[0]
+}
+
+// cellRange returns first and last slot of cell
+func (h *heatmap) cellRange(c int) (start int, end int) {
+	start = c * h.cell
+	end = start + h.cell - 1
+	if end >= rcc.SlotCount {
+		end = rcc.SlotCount - 1
+	}
+	return start, end
+}
+
+// cellKeys returns keys in cell, counted is false when any slot of cell is not counted
+func (h *heatmap) cellKeys(c int) (sum int64, counted bool) {
+	start, end := h.cellRange(c)
+	counted = true
+	for slot := start; slot <= end; slot++ {
+		if h.keys[slot] < 0 {
+			counted = false
+			continue
+		}
+		sum += h.keys[slot]
+	}
+	return sum, counted
+}
+
+// ownerMarks returns mark of every master owning slots in order of first slot
+func (h *heatmap) ownerMarks() map[string]rune {
+	marks := make(map[string]rune)
+	for _, id := range h.owners {
+		if id == "" {
+			continue
+		}
+		if _, ok := marks[id]; ok {
+			continue
+		}
+		if len(marks) < len(ownerSymbols) {
+			marks[id] = rune(ownerSymbols[len(marks)])
+		} else {
+			marks[id] = sharedMark
+		}
+	}
+	return marks
+}
+
+// ownerAttr returns color of master in order of first slot, masters sharing mark are colored too
+func (h *heatmap) ownerAttr(id string) string {
+	i, ok := h.order[id]
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("\x1b[38;5;%dm", ownerColors[i%len(ownerColors)])
+}
+
+// legend writes masters with their marks, and marks of key count or open and uncovered slots
+func (h *heatmap) legend(w io.Writer, marks map[string]rune, max int64) {
+	fmt.Fprint(w, "\n")
+	slots := make(map[string]int)
+	for _, id := range h.owners {
+		slots[id]++
+	}
+	for _, id := range orderedOwners(h.owners) {
+		var addr string
+		for _, node := range h.nodes {
+			if node.ID == id {
+				addr = node.Addr()
+			}
+		}
+		mark := string(marks[id])
+		if h.colored && h.keys == nil {
+			mark = h.ownerAttr(id) + mark + "\x1b[0m"
+		}
+		fmt.Fprintf(w, "  %s %s %s slots:%d\n", mark, id, addr, slots[id])
+	}
+	if h.keys == nil {
+		fmt.Fprintf(w, "  lower case cell is split between masters, %c split among masters marked %c\n", sharedSplit, sharedMark)
+		if shared := len(marks) - len(ownerSymbols); shared > 0 {
+			fmt.Fprintf(w, "  warning: %d masters from %dth are all marked %c and not told apart, use --by keys or fewer masters\n",
+				shared, len(ownerSymbols)+1, sharedMark)
+		}
+	} else {
+		fmt.Fprintf(w, "  %q keys from none to %d per cell\n", keysRamp, max)
+	}
+	fmt.Fprintf(w, "  %c uncovered", uncoveredMark)
+	if h.keys != nil {
+		fmt.Fprint(w, " or uncounted")
+	}
+	fmt.Fprintf(w, " slot:%d  %c open slot:%d\n", slots[""], openMark, len(h.open))
+	for _, slot := range sortedOpenSlots(h.open) {
+		for _, s := range h.open[slot] {
+			if s.To != "" {
+				fmt.Fprintf(w, "    slot %d migrating to %s\n", slot, s.To)
+			} else {
+				fmt.Fprintf(w, "    slot %d importing from %s\n", slot, s.From)
+			}
+		}
+	}
+}
+
+// orderedOwners returns masters in order of first slot
+func orderedOwners(owners []string) (ids []string) {
+	seen := make(map[string]bool)
+	for _, id := range owners {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func sortedOpenSlots(open map[int][]rcc.Slot) (slots []int) {
+	for slot := 0; slot < rcc.SlotCount; slot++ {
+		if len(open[slot]) > 0 {
+			slots = append(slots, slot)
+		}
+	}
+	return slots
+}
+
+// useColor returns whether to color output from --color always, never or auto of terminal stdout
+func useColor(color string) (bool, error) {
+	switch color {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	case "auto":
+		if os.Getenv("NO_COLOR") != "" {
+			return false, nil
+		}
+		fi, err := os.Stdout.Stat()
+		return err == nil && fi.Mode()&os.ModeCharDevice != 0, nil
+	}
+	err := errors.New(fmt.Sprintf("Unknown --color %q, auto, always or never is expected", color))
+	return false, errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+}
+
+func heatmapUsage() {
+	helpText := `
+usage:
+   {{.Name}} [command options] [<HOST:PORT>[,<HOST:PORT>...]]
+
+version:
+   {{.Version}}
+
+author:
+   kizkoh<GitHub: https://github.com/kizkoh>
+
+options:
+   --by <owner|keys>                            Color slots by owning master or by keys in slot (default: owner)
+   --width <N>                                  Cells per row (default: 64)
+   --cell <N>                                   Slots per cell (default: 16)
+   --color <auto|always|never>                  Color cells with ANSI escape (default: auto)
+   --pipeline <N>                               Slots counted in one round trip with --by keys (default: 1000)
+   --parallel <N>                               Shards counted concurrently with --by keys (default: 8)
+   --help, -h                                   Show help
+
+global options:
+{{.GlobalOptions}}
+`
+	printUsage(App.Name+" heatmap", helpText)
+}
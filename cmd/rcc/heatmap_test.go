@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/kizkoh/rcc/rcc"
+	"github.com/kizkoh/rcc/rcc/rcctest"
+)
+
+// renderCells returns marks of every cell and legend of heatmap rendered without color
+func renderCells(t *testing.T, h *heatmap) (cells string, legend string) {
+	t.Helper()
+	var b bytes.Buffer
+	h.Render(&b)
+	lines := strings.Split(b.String(), "\n")
+	i := 1
+	for ; i < len(lines) && lines[i] != ""; i++ {
+		cells += lines[i][6:]
+	}
+	return cells, strings.Join(lines[i:], "\n")
+}
+
+func TestHeatmapRender(t *testing.T) {
+	// A owns cell 0 and most of cell 1, B owns the rest but last cell, slot 5000 of B is migrating to A
+	cluster, err := rcctest.NewCluster(
+		rcctest.Spec{Slots: []rcc.Slot{{Start: 0, End: 159}}},
+		rcctest.Spec{Slots: []rcc.Slot{{Start: 160, End: 16283}}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cluster.Close()
+	a, b := cluster.Servers[0], cluster.Servers[1]
+	for i := 0; i < 10; i++ {
+		a.Set(fmt.Sprintf("{06S}%d", i), "v")
+	}
+	manager := rcc.NewManager(rcc.DefaultClientOptions)
+	defer manager.Close()
+	ctx := context.Background()
+	if _, err := manager.Client(b.Addr).(rcc.Commander).Do(ctx, "cluster", "setslot", "5000", "migrating", a.ID); err != nil {
+		t.Fatal(err)
+	}
+	nodes, err := rcc.ClusterNodesContext(ctx, manager.Client(a.Addr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	open, err := rcc.OpenSlots(ctx, manager, nodes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := rcc.CountKeysInSlots(ctx, manager, nodes, rcc.DefaultCountOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		keys   []int64
+		marks  map[int]byte // mark of cell
+		legend []string
+	}{
+		{
+			name:   "owner",
+			marks:  map[int]byte{0: 'A', 1: 'a', 2: 'B', 50: '!', 162: 'x', 163: 'x'},
+			legend: []string{"A " + a.ID, "B " + b.ID, "x uncovered slot:100", "! open slot:1", "slot 5000 migrating to " + a.ID},
+		},
+		{
+			name:   "keys",
+			keys:   keys,
+			marks:  map[int]byte{0: '@', 1: ' ', 2: ' ', 50: '!', 163: 'x'},
+			legend: []string{"keys from none to 10 per cell", "x uncovered or uncounted slot:100"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &heatmap{cell: 100, width: 64, owners: rcc.SlotOwners(nodes), nodes: nodes, open: open, keys: tt.keys}
+			cells, legend := renderCells(t, h)
+			if len(cells) != 164 {
+				t.Fatalf("Render() draws %d cells, want 164:\n%s", len(cells), cells)
+			}
+			for c, want := range tt.marks {
+				if cells[c] != want {
+					t.Errorf("cell %d = %q, want %q", c, cells[c], want)
+				}
+			}
+			for _, want := range tt.legend {
+				if !strings.Contains(legend, want) {
+					t.Errorf("legend does not contain %q:\n%s", want, legend)
+				}
+			}
+			if strings.Contains(cells+legend, "\x1b[") {
+				t.Errorf("Render() without color writes escape:\n%s", cells+legend)
+			}
+
+			// command draws same heatmap
+			args := []string{"heatmap", "--color", "never", "--cell", "100", a.Addr}
+			if tt.keys != nil {
+				args = append(args[:1], append([]string{"--by", "keys"}, args[1:]...)...)
+			}
+			var want bytes.Buffer
+			h.Render(&want)
+			if stdout := mustRun(t, args...); stdout != want.String() {
+				t.Errorf("%s prints\n%s\nwant\n%s", strings.Join(args, " "), stdout, want.String())
+			}
+		})
+	}
+}
+
+func TestHeatmapManyMasters(t *testing.T) {
+	// 66 masters own 250 slots each, every other cell is split between two masters
+	owners := make([]string, rcc.SlotCount)
+	for slot := range owners {
+		owners[slot] = fmt.Sprintf("%040d", slot/250)
+	}
+	h := &heatmap{cell: 100, width: 64, owners: owners}
+	cells, legend := renderCells(t, h)
+	for c, want := range map[int]byte{0: 'A', 2: 'a', 3: 'B', 60: 'Z', 62: 'z', 63: '#', 67: '+'} {
+		if cells[c] != want {
+			t.Errorf("cell %d = %q, want %q", c, cells[c], want)
+		}
+	}
+	if !strings.Contains(legend, "warning: 41 masters from 26th are all marked #") {
+		t.Errorf("legend does not warn masters sharing mark:\n%s", legend)
+	}
+}
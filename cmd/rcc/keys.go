@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kizkoh/rcc/rcc"
+	"github.com/pkg/errors"
+)
+
+func runKeys(ctx context.Context, g *Global, args []string) error {
+	var (
+		slotRanges = ""
+		owner      = ""
+		opt        = rcc.DefaultExportOptions
+		asJSON     = false
+		help       = false
+	)
+
+	// parse args
+	flags := flag.NewFlagSet("keys", flag.ContinueOnError)
+
+	flags.StringVar(&slotRanges, "slots", slotRanges, "slots")
+	flags.StringVar(&owner, "node", owner, "node")
+	flags.IntVar(&opt.Limit, "limit", opt.Limit, "limit")
+	flags.IntVar(&opt.Max, "max-keys", opt.Max, "max-keys")
+	flags.IntVar(&opt.Batch, "pipeline", opt.Batch, "pipeline")
+	flags.BoolVar(&asJSON, "json", asJSON, "json")
+	g.SetFlags(flags)
+	flags.BoolVar(&help, "h", help, "help")
+	flags.BoolVar(&help, "help", help, "help")
+
+	flags.Usage = func() { keysUsage() }
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if help || flags.NArg() > 1 || (slotRanges == "") == (owner == "") {
+		keysUsage()
+		return nil
+	}
+
+	if err := g.InitLogger(); err != nil {
+		return err
+	}
+
+	nodes, err := g.clusterNodes(ctx, "", flags.Args())
+	if err != nil {
+		return err
+	}
+	manager, err := g.Manager()
+	if err != nil {
+		return err
+	}
+	owners := rcc.SlotOwners(nodes)
+
+	var slots []int
+	if slotRanges != "" {
+		for _, r := range strings.Split(slotRanges, ",") {
+			start, end, err := rcc.ParseSlotRange(strings.TrimSpace(r))
+			if err != nil {
+				return err
+			}
+			for slot := start; slot <= end; slot++ {
+				slots = append(slots, slot)
+			}
+		}
+	} else {
+		// HOST:PORT is resolved to address of node as other commands do
+		name := owner
+		if strings.Contains(owner, ":") {
+			if name, err = g.nodeAddr(ctx, owner); err != nil {
+				return err
+			}
+		}
+		// slots of slave are read from its master
+		node, ok := findNode(nodes, name)
+		if !ok {
+			err := errors.New(fmt.Sprintf("Node %s is not found in cluster", owner))
+			return errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+		}
+		master := GetMasterNode(nodes, node)
+		for slot, id := range owners {
+			if id == master.ID {
+				slots = append(slots, slot)
+			}
+		}
+	}
+
+	byID := make(map[string]rcc.ClusterNode)
+	for _, node := range nodes {
+		byID[node.ID] = node
+	}
+	client := func(slot int) (rcc.Client, error) {
+		node, ok := byID[owners[slot]]
+		if !ok {
+			err := errors.New(fmt.Sprintf("Slot %d is not covered", slot))
+			return nil, errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+		}
+		return manager.NodeClient(node), nil
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	enc := json.NewEncoder(w)
+	opt.Details = asJSON
+	err = rcc.ExportKeys(ctx, client, slots, opt, func(key rcc.SlotKey) error {
+		if asJSON {
+			return enc.Encode(key)
+		}
+		_, err := fmt.Fprintln(w, key.Key)
+		return err
+	})
+	if ferr := w.Flush(); ferr != nil && err == nil {
+		err = ferr
+	}
+	return err
+}
+
+// findNode returns node of ID, ID prefix or resolved address in cluster
+func findNode(nodes []rcc.ClusterNode, name string) (rcc.ClusterNode, bool) {
+	for _, node := range nodes {
+		if node.ID == name || node.Addr() == name {
+			return node, true
+		}
+	}
+	var found []rcc.ClusterNode
+	for _, node := range nodes {
+		if strings.HasPrefix(node.ID, name) {
+			found = append(found, node)
+		}
+	}
+	if len(found) == 1 {
+		return found[0], true
+	}
+	return rcc.ClusterNode{}, false
+}
+
+func keysUsage() {
+	helpText := `
+usage:
+   {{.Name}} [command options] [<HOST:PORT>[,<HOST:PORT>...]]
+
+version:
+   {{.Version}}
+
+author:
+   kizkoh<GitHub: https://github.com/kizkoh>
+
+options:
+   --slots <SLOT|START-END>[,...]               Print keys of slots
+   --node <ID|HOST:PORT>                        Print keys of slots owned by node, or by its master
+   --limit <N>                                  Keys printed at most per slot (default: every key)
+   --max-keys <N>                               Keys read at most from slot in one reply, more are warned and skipped (default: 10000)
+   --pipeline <N>                               Keys of which type and TTL are read in one round trip (default: 1000)
+   --json                                       Print slot, key, type and TTL in milliseconds of each key as JSON line
+   --help, -h                                   Show help
+
+global options:
+{{.GlobalOptions}}
+`
+	printUsage(App.Name+" keys", helpText)
+}
@@ -0,0 +1,484 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/template"
+
+	"github.com/kizkoh/rcc/rcc"
+	"github.com/pkg/errors"
+)
+
+// Global is options shared by every subcommand, it is accepted before and after subcommand name
+type Global struct {
+	ClientOptions rcc.ClientOptions
+	ConfigPath    string
+	ClusterName   string
+	CrossCheck    bool
+	NoResolve     bool
+	Verbose       bool
+	LogLevel      string
+	LogFormat     string
+	AuditLog      string
+	Operator      string
+
+	commandLine []string
+	config      *rcc.Config
+	profile     *rcc.ClusterProfile
+	manager     *rcc.Manager
+}
+
+// SetFlags register global options into flags
+func (g *Global) SetFlags(flags *flag.FlagSet) {
+	g.ClientOptions.SetFlags(flags)
+	flags.StringVar(&g.ConfigPath, "config", g.ConfigPath, "config")
+	flags.StringVar(&g.ClusterName, "cluster-name", g.ClusterName, "cluster-name")
+	flags.BoolVar(&g.CrossCheck, "cross-check", g.CrossCheck, "cross-check")
+	flags.BoolVar(&g.NoResolve, "no-resolve", g.NoResolve, "no-resolve")
+	flags.BoolVar(&g.Verbose, "verbose", g.Verbose, "verbose")
+	flags.StringVar(&g.LogLevel, "log-level", g.LogLevel, "log-level")
+	flags.StringVar(&g.LogFormat, "log-format", g.LogFormat, "log-format")
+	flags.StringVar(&g.AuditLog, "audit-log", g.AuditLog, "audit-log")
+	flags.StringVar(&g.Operator, "operator", g.Operator, "operator")
+}
+
+// InitLogger configure rcc.DefaultLogger with log options, --verbose is same as --log-level debug
+func (g *Global) InitLogger() error {
+	level, err := rcc.ParseLevel(g.LogLevel)
+	if err != nil {
+		return err
+	}
+	if g.Verbose {
+		level = rcc.LevelDebug
+	}
+	var json bool
+	switch g.LogFormat {
+	case "text":
+	case "json":
+		json = true
+	default:
+		err := errors.New(fmt.Sprintf("Unknown log format '%s'", g.LogFormat))
+		return errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+	}
+	rcc.DefaultLogger = rcc.NewLogger(os.Stderr, level, json)
+	return nil
+}
+
+// Config returns config file, missing default config file is empty config
+func (g *Global) Config() (*rcc.Config, error) {
+	if g.config != nil {
+		return g.config, nil
+	}
+	path := g.ConfigPath
+	if path == "" {
+		path = rcc.DefaultConfigPath()
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			g.config = &rcc.Config{}
+			return g.config, nil
+		}
+	}
+	config, err := rcc.LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	g.config = &config
+	return g.config, nil
+}
+
+// Profile returns cluster profile named by --cluster-name, or nil without --cluster-name
+func (g *Global) Profile() (*rcc.ClusterProfile, error) {
+	if g.ClusterName == "" || g.profile != nil {
+		return g.profile, nil
+	}
+	config, err := g.Config()
+	if err != nil {
+		return nil, err
+	}
+	profile, err := config.Cluster(g.ClusterName)
+	if err != nil {
+		return nil, err
+	}
+	g.profile = &profile
+	return g.profile, nil
+}
+
+// Seeds returns node addresses given as arguments, or seeds of cluster profile
+func (g *Global) Seeds(args []string) ([]string, error) {
+	if seeds := rcc.SplitSeeds(args...); len(seeds) > 0 {
+		return seeds, nil
+	}
+	profile, err := g.Profile()
+	if err != nil {
+		return nil, err
+	}
+	if profile != nil {
+		return profile.Seeds, nil
+	}
+	return []string{"127.0.0.1:6379"}, nil
+}
+
+// Manager returns connection manager configured with global options
+func (g *Global) Manager() (*rcc.Manager, error) {
+	if g.manager == nil {
+		profile, err := g.Profile()
+		if err != nil {
+			return nil, err
+		}
+		if profile != nil {
+			profile.Apply(&g.ClientOptions)
+		}
+		if g.NoResolve {
+			rcc.DefaultResolver = nil
+		}
+		if err := g.ClientOptions.Load(); err != nil {
+			return nil, err
+		}
+		g.manager = rcc.NewManager(g.ClientOptions)
+	}
+	return g.manager, nil
+}
+
+// Close closes connections opened by subcommand
+func (g *Global) Close() error {
+	if g.manager == nil {
+		return nil
+	}
+	return g.manager.Close()
+}
+
+type command struct {
+	Name    string
+	Summary string
+	Run     func(ctx context.Context, g *Global, args []string) error
+}
+
+var commands = []command{
+	{Name: "tree", Summary: "Print cluster nodes as tree", Run: runTree},
+	{Name: "replication", Summary: "Print replication offset and lag of replicas", Run: runReplication},
+	{Name: "whoami", Summary: "Print node and its master or slaves", Run: runWhoami},
+	{Name: "add-slave", Summary: "Add empty node as slave of master", Run: runAddSlave},
+	{Name: "count-key-slot", Summary: "Print slots and keys per shard", Run: runCountKeySlot},
+	{Name: "keys", Summary: "Print keys of slots with their type and TTL", Run: runKeys},
+	{Name: "slot-memory", Summary: "Estimate bytes per slot by sampling keys", Run: runSlotMemory},
+	{Name: "heatmap", Summary: "Print slots as grid colored by owner or keys", Run: runHeatmap},
+	{Name: "reconcile", Summary: "Converge cluster into desired state file", Run: runReconcile},
+	{Name: "rebalance", Summary: "Move slots so that keys or memory of masters are even", Run: runRebalance},
+	{Name: "apply", Summary: "Check preconditions of plan file and run it", Run: runApply},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	var help = false
+	var version = false
+	var g = Global{ClientOptions: rcc.DefaultClientOptions, LogLevel: "info", LogFormat: "text"}
+
+	// parse args, parsing stops at subcommand name
+	flags := flag.NewFlagSet(App.Name, flag.ContinueOnError)
+
+	g.SetFlags(flags)
+	flags.BoolVar(&help, "h", help, "help")
+	flags.BoolVar(&help, "help", help, "help")
+	flags.BoolVar(&version, "version", version, "version")
+
+	flags.Usage = func() { usage() }
+	if err := flags.Parse(args); err != nil {
+		err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+		fmt.Printf("%v-%v failed: %v\n", App.Name, App.Version, err)
+		return 1
+	}
+
+	if version {
+		fmt.Printf("%v version %v\n", App.Name, App.Version)
+		return 0
+	}
+	if help || flags.NArg() == 0 {
+		usage()
+		return 0
+	}
+
+	name, subArgs := flags.Arg(0), flags.Args()[1:]
+	if name == "help" && len(subArgs) > 0 {
+		name, subArgs = subArgs[0], []string{"--help"}
+	}
+	// first interrupt cancels running command, second one kills rcc as usual
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	for _, c := range commands {
+		if c.Name != name {
+			continue
+		}
+		g.commandLine = append([]string{App.Name, name}, subArgs...)
+		err := c.Run(ctx, &g, subArgs)
+		if cerr := g.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v-%v failed: %v\n", App.Name, App.Version, err)
+			return 1
+		}
+		return 0
+	}
+
+	// global options given before subcommand name are passed to plugin as they are
+	globalArgs := args[:len(args)-flags.NArg()]
+	code, err := runPlugin(name, append(globalArgs, subArgs...))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v-%v failed: %v\n", App.Name, App.Version, err)
+	}
+	return code
+}
+
+// globalOptions is help text of global options shared by every usage
+const globalOptions = `
+   --user <USER>                                ACL user name, or RCC_USER
+   --password <PASSWORD>                        Password, or RCC_AUTH or REDISCLI_AUTH
+   --password-file <FILE>                       Read password from file
+   --tls                                        Connect with TLS
+   --cacert <FILE>                              CA certificate to verify nodes
+   --cert <FILE>                                Client certificate
+   --key <FILE>                                 Client private key
+   --sni <HOST>                                 Server name to verify and send as SNI
+   --insecure                                   Skip node certificate verification
+   --dial-timeout <DURATION>                    Timeout to connect a node (default: 5s)
+   --timeout <DURATION>                         Timeout to read and write a node (default: 10s)
+   --retries <N>                                Retries of a failed read-only command (default: 1)
+   --config <FILE>                              Config file (default: ~/.config/rcc/config.yaml)
+   --cluster-name <NAME>                        Use seeds, auth and TLS of named cluster in config file
+   --cross-check                                Compare topology with another seed
+   --no-resolve                                 Print IP address without reverse DNS lookup
+   --verbose                                    Log debug messages and every command sent to nodes
+   --log-level <LEVEL>                          Log level, debug, info, warn or error (default: info)
+   --log-format <FORMAT>                        Log format, text or json (default: text)
+   --audit-log <FILE|syslog[:TAG]>              Append record of mutating command, or audit-log in config file
+   --operator <NAME>                            Operator name in audit record, or RCC_OPERATOR, SUDO_USER or login user
+`
+
+// printUsage print help text, {{.Name}} is replaced with command name and {{.GlobalOptions}} with global options
+func printUsage(name string, helpText string) {
+	data := struct {
+		Name          string
+		Version       string
+		GlobalOptions string
+	}{
+		Name:          name,
+		Version:       App.Version,
+		GlobalOptions: strings.Trim(globalOptions, "\n"),
+	}
+	t := template.New("usage")
+	t, _ = t.Parse(strings.TrimSpace(helpText))
+	t.Execute(os.Stdout, data)
+	fmt.Println()
+}
+
+func usage() {
+	helpText := `
+usage:
+   {{.Name}} [global options] <command> [command options] [arguments...]
+
+version:
+   {{.Version}}
+
+author:
+   kizkoh<GitHub: https://github.com/kizkoh>
+
+commands:
+{{- range .Commands}}
+   {{printf "%-45s" .Name}}{{.Summary}}
+{{- end}}
+{{- range .Plugins}}
+   {{printf "%-45s" .}}Plugin command found on PATH
+{{- end}}
+
+global options:
+{{.GlobalOptions}}
+   --help, -h                                   Show help
+   --version                                    Print the version
+`
+	data := struct {
+		Name          string
+		Version       string
+		Commands      []command
+		Plugins       []string
+		GlobalOptions string
+	}{
+		Name:          App.Name,
+		Version:       App.Version,
+		Commands:      commands,
+		Plugins:       findPlugins(),
+		GlobalOptions: strings.Trim(globalOptions, "\n"),
+	}
+	t := template.New("usage")
+	t, _ = t.Parse(strings.TrimSpace(helpText))
+	t.Execute(os.Stdout, data)
+	fmt.Println()
+}
+
+// nodeLabel returns host label and zone of node in cluster profile, host is node host without label
+func (g *Global) nodeLabel(node rcc.ClusterNode) (host string, zone string) {
+	host = node.Host
+	if g.profile == nil {
+		return host, ""
+	}
+	labels, ok := g.profile.Labels(node)
+	if !ok {
+		return host, ""
+	}
+	if labels.Host != "" {
+		host = labels.Host
+	}
+	return host, labels.Zone
+}
+
+// nodeAddr returns HOST:PORT of node named by addr, hostname mapping to several nodes is an error
+func (g *Global) nodeAddr(ctx context.Context, addr string) (string, error) {
+	manager, err := g.Manager()
+	if err != nil {
+		return "", err
+	}
+	return rcc.ResolveNodeContext(ctx, manager, addr)
+}
+
+// nodeClusterNodes returns cluster nodes read from file, or seen from first node given as argument without failover
+func (g *Global) nodeClusterNodes(ctx context.Context, fromFile string, args []string) (cluster []rcc.ClusterNode, err error) {
+	if fromFile != "" {
+		return g.clusterNodes(ctx, fromFile, args)
+	}
+	seeds, err := g.Seeds(args)
+	if err != nil {
+		return nil, err
+	}
+	addr, err := g.nodeAddr(ctx, seeds[0])
+	if err != nil {
+		return nil, err
+	}
+	return rcc.ClusterNodesContext(ctx, g.manager.Client(addr))
+}
+
+// clusterNodes returns cluster nodes read from file, or from first reachable node given as argument
+func (g *Global) clusterNodes(ctx context.Context, fromFile string, args []string) (cluster []rcc.ClusterNode, err error) {
+	if _, err := g.Profile(); err != nil {
+		return nil, err
+	}
+	if fromFile != "" {
+		conf, err := rcc.ReadNodesConf(fromFile)
+		if err != nil {
+			return nil, err
+		}
+		return conf.Nodes, nil
+	}
+
+	seeds, err := g.Seeds(args)
+	if err != nil {
+		return nil, err
+	}
+	manager, err := g.Manager()
+	if err != nil {
+		return nil, err
+	}
+	discovery, err := rcc.DiscoverContext(ctx, manager, seeds)
+	if err != nil {
+		return nil, err
+	}
+	for seed, err := range discovery.Errors {
+		rcc.DefaultLogger.Warn("seed is skipped", "seed", seed, "error", err)
+	}
+	rcc.DefaultLogger.Debug("cluster nodes are discovered", "seed", discovery.Seed)
+
+	if g.CrossCheck {
+		other, diffs, err := rcc.CrossCheckContext(ctx, manager, discovery, seeds)
+		if err != nil {
+			return nil, err
+		}
+		for _, diff := range diffs {
+			rcc.DefaultLogger.Warn("seeds disagree", "seed", discovery.Seed, "other", other, "diff", diff)
+		}
+	}
+	return discovery.Nodes, nil
+}
+
+// audit runs mutating fn and appends its record to audit log, topology is read from node addr before and after fn
+//
+// Mutating command is not run when audit log is configured but can not be opened or topology before it can not be read.
+func (g *Global) audit(ctx context.Context, addr string, fn func() error) (err error) {
+	dest := g.AuditLog
+	if dest == "" {
+		config, err := g.Config()
+		if err != nil {
+			return err
+		}
+		dest = config.AuditLog
+	}
+	if dest == "" {
+		return fn()
+	}
+	w, err := rcc.OpenAuditLog(dest)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	manager, err := g.Manager()
+	if err != nil {
+		return err
+	}
+
+	operator := g.Operator
+	if operator == "" {
+		operator = rcc.Operator()
+	}
+	cluster := g.ClusterName
+	if cluster == "" {
+		cluster = addr
+	}
+	audit := rcc.NewAudit(operator, cluster, g.CommandLine())
+	client := manager.Client(addr)
+	if err := audit.Before(ctx, client); err != nil {
+		return err
+	}
+	rcc.DefaultAudit = audit
+	err = fn()
+	rcc.DefaultAudit = nil
+	// topology after failed or interrupted command is still recorded
+	if aerr := audit.After(context.Background(), client); aerr != nil {
+		rcc.DefaultLogger.Warn("topology after command is not recorded", "node", addr, "error", aerr)
+	}
+	if werr := w.WriteAudit(audit.Record(err)); werr != nil && err == nil {
+		err = werr
+	}
+	return err
+}
+
+// CommandLine returns command line running subcommand, values of password options are masked
+func (g *Global) CommandLine() string {
+	return strings.Join(maskArgs(g.commandLine), " ")
+}
+
+// maskArgs returns command line args with values of password options masked
+func maskArgs(args []string) []string {
+	masked := make([]string, len(args))
+	copy(masked, args)
+	for i, arg := range masked {
+		name := strings.TrimLeft(arg, "-")
+		if !strings.HasPrefix(arg, "-") || !strings.HasPrefix(name, "password") || strings.HasPrefix(name, "password-file") {
+			continue
+		}
+		if strings.Contains(name, "=") {
+			masked[i] = arg[:strings.Index(arg, "=")+1] + "********"
+		} else if i+1 < len(masked) {
+			masked[i+1] = "********"
+		}
+	}
+	return masked
+}
@@ -0,0 +1,476 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/kizkoh/rcc/rcc"
+	"github.com/kizkoh/rcc/rcc/rcctest"
+)
+
+// runCommand runs rcc with args and returns its exit code, standard output and standard error
+func runCommand(t *testing.T, args ...string) (code int, stdout string, stderr string) {
+	t.Helper()
+	// config file of user running test is not read
+	t.Setenv("RCC_CONFIG", filepath.Join(t.TempDir(), "config.yaml"))
+
+	outR, outW, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	errR, errW, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	outc, errc := readAll(outR), readAll(errR)
+	origOut, origErr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = outW, errW
+	defer func() {
+		os.Stdout, os.Stderr = origOut, origErr
+	}()
+
+	code = run(append([]string{"--no-resolve"}, args...))
+	outW.Close()
+	errW.Close()
+	return code, <-outc, <-errc
+}
+
+func readAll(f *os.File) <-chan string {
+	c := make(chan string, 1)
+	go func() {
+		b, _ := ioutil.ReadAll(f)
+		f.Close()
+		c <- string(b)
+	}()
+	return c
+}
+
+// mustRun runs rcc with args and fails test when it exits with error
+func mustRun(t *testing.T, args ...string) string {
+	t.Helper()
+	code, stdout, stderr := runCommand(t, args...)
+	if code != 0 {
+		t.Fatalf("rcc %s exited with %d\nstdout:\n%s\nstderr:\n%s", strings.Join(args, " "), code, stdout, stderr)
+	}
+	return stdout
+}
+
+func newCluster(t *testing.T, masters int, replicas int) *rcctest.Cluster {
+	t.Helper()
+	cluster, err := rcctest.NewEvenCluster(masters, replicas)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cluster.Close)
+	return cluster
+}
+
+// setKeys sets n keys on masters owning their slots
+func setKeys(t *testing.T, cluster *rcctest.Cluster, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		key := fmt.Sprintf("key:%d", i)
+		cluster.Server(cluster.Owner(rcc.KeySlot(key))).Set(key, "value")
+	}
+}
+
+// dbsize returns number of keys of node
+func dbsize(t *testing.T, s *rcctest.Server) int64 {
+	t.Helper()
+	client := rcc.NewClient(s.Addr, rcc.DefaultClientOptions)
+	defer client.Close()
+	val, err := client.(rcc.Commander).Do(context.Background(), "dbsize")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return val.(int64)
+}
+
+func TestTree(t *testing.T) {
+	cluster := newCluster(t, 3, 1)
+
+	stdout := mustRun(t, "tree", cluster.Servers[0].Addr)
+	for _, s := range cluster.Servers {
+		if !strings.Contains(stdout, s.ID) {
+			t.Errorf("tree does not print node %s:\n%s", s.ID, stdout)
+		}
+	}
+
+	stdout = mustRun(t, "tree", "--master", cluster.Servers[0].Addr)
+	for _, s := range cluster.Servers {
+		if master := s.SlaveOf() == ""; strings.Contains(stdout, s.ID) != master {
+			t.Errorf("tree --master prints node %s = %v, want %v:\n%s", s.ID, !master, master, stdout)
+		}
+	}
+}
+
+func TestWhoami(t *testing.T) {
+	cluster := newCluster(t, 3, 1)
+	slave := cluster.Servers[1]
+	master := slave.SlaveOf()
+
+	stdout := mustRun(t, "whoami", slave.Addr)
+	if !strings.Contains(stdout, slave.ID) || !strings.Contains(stdout, master) {
+		t.Errorf("whoami of slave does not print it and master %s:\n%s", master, stdout)
+	}
+}
+
+func TestAddSlave(t *testing.T) {
+	cluster := newCluster(t, 3, 0)
+	master := cluster.Servers[0]
+	slave, err := cluster.AddNode(rcctest.Spec{Alone: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stdout := mustRun(t, "add-slave", slave.Addr, master.Addr); !strings.Contains(stdout, "configure node as replica of "+master.Addr) {
+		t.Errorf("add-slave does not print progress:\n%s", stdout)
+	}
+	if got := slave.SlaveOf(); got != master.ID {
+		t.Errorf("master of new node = %q, want %q", got, master.ID)
+	}
+
+	// node which is not empty any more is refused
+	code, _, _ := runCommand(t, "add-slave", slave.Addr, cluster.Servers[1].Addr)
+	if code == 0 {
+		t.Error("add-slave of node in cluster succeeded")
+	}
+
+	// master whose CLUSTER NODES has no myself is refused before replicating empty node ID
+	other, err := cluster.AddNode(rcctest.Spec{Alone: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	master.SetClusterNodes(fmt.Sprintf("%s %s@1%d master - 0 0 1 connected 0-16383\n", master.ID, master.Addr, master.Port))
+	code, _, stderr := runCommand(t, "add-slave", other.Addr, master.Addr)
+	if code == 0 || !strings.Contains(stderr, rcc.ErrMyselfNotFound.Error()) {
+		t.Errorf("add-slave of master without myself exits %d:\n%s", code, stderr)
+	}
+	if got := other.SlaveOf(); got != "" {
+		t.Errorf("master of node = %q, want none", got)
+	}
+}
+
+func TestCountKeySlot(t *testing.T) {
+	cluster := newCluster(t, 3, 0)
+	setKeys(t, cluster, 300)
+
+	// only shard of seed is counted without --cluster
+	seed := cluster.Servers[0]
+	stdout := mustRun(t, "count-key-slot", seed.Addr)
+	if want := fmt.Sprintf("%s %s [myself,master] slots: 5461 count:%8d", seed.ID, seed.Addr, dbsize(t, seed)); !strings.Contains(stdout, want) {
+		t.Errorf("count-key-slot does not print %q:\n%s", want, stdout)
+	}
+	for _, s := range cluster.Servers[1:] {
+		if strings.Contains(stdout, s.ID) {
+			t.Errorf("count-key-slot prints other master %s:\n%s", s.ID, stdout)
+		}
+	}
+
+	stdout = mustRun(t, "count-key-slot", "--cluster", seed.Addr)
+	for _, s := range cluster.Servers {
+		if want := fmt.Sprintf("count:%8d", dbsize(t, s)); !strings.Contains(stdout, s.ID) || !strings.Contains(stdout, want) {
+			t.Errorf("count-key-slot --cluster does not print master %s with %q:\n%s", s.ID, want, stdout)
+		}
+	}
+	if !strings.Contains(stdout, "keys                  100.0") {
+		t.Errorf("count-key-slot --cluster does not print mean of 100 keys:\n%s", stdout)
+	}
+
+	// max/min of no keys is printed as "-"
+	empty := newCluster(t, 2, 0)
+	stdout = mustRun(t, "count-key-slot", "--cluster", empty.Servers[0].Addr)
+	if !regexp.MustCompile(`(?m)^keys +0\.0 +0\.0 +0 +0 +-$`).MatchString(stdout) {
+		t.Errorf("count-key-slot --cluster of no keys does not print max/min as -:\n%s", stdout)
+	}
+	if !strings.Contains(stdout, "best move:   none reduces imbalance of keys") {
+		t.Errorf("count-key-slot --cluster of no keys prints move:\n%s", stdout)
+	}
+}
+
+func TestApply(t *testing.T) {
+	cluster := newCluster(t, 3, 0)
+	master := cluster.Servers[0]
+	slave, err := cluster.AddNode(rcctest.Spec{Alone: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	planFile := filepath.Join(t.TempDir(), "plan.json")
+
+	mustRun(t, "add-slave", "--plan", planFile, slave.Addr, master.Addr)
+	if got := slave.SlaveOf(); got != "" {
+		t.Fatalf("add-slave --plan changed master of new node into %q", got)
+	}
+	mustRun(t, "apply", "--check", planFile)
+	if got := slave.SlaveOf(); got != "" {
+		t.Fatalf("apply --check changed master of new node into %q", got)
+	}
+	mustRun(t, "apply", planFile)
+	if got := slave.SlaveOf(); got != master.ID {
+		t.Errorf("master of new node = %q, want %q", got, master.ID)
+	}
+
+	// preconditions do not hold once plan ran
+	code, _, _ := runCommand(t, "apply", planFile)
+	if code == 0 {
+		t.Error("apply of stale plan succeeded")
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	cluster := newCluster(t, 2, 0)
+	setKeys(t, cluster, 200)
+	a, b := cluster.Servers[0], cluster.Servers[1]
+	desired := filepath.Join(t.TempDir(), "desired.yaml")
+	state := fmt.Sprintf("masters:\n  - {addr: %s, slots: [\"0-4095\"]}\n  - {addr: %s, slots: [\"4096-16383\"]}\n", a.Addr, b.Addr)
+	if err := ioutil.WriteFile(desired, []byte(state), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mustRun(t, "reconcile", desired, a.Addr)
+	for slot, want := range map[int]string{0: a.ID, 4095: a.ID, 4096: b.ID, 8191: b.ID, 16383: b.ID} {
+		if got := cluster.Owner(slot); got != want {
+			t.Errorf("owner of slot %d = %s, want %s", slot, got, want)
+		}
+	}
+	if n := dbsize(t, a) + dbsize(t, b); n != 200 {
+		t.Errorf("masters have %d keys after reconcile, want 200", n)
+	}
+
+	stdout := mustRun(t, "reconcile", desired, a.Addr)
+	if !strings.Contains(stdout, "cluster is in desired state") {
+		t.Errorf("reconcile of reconciled cluster is not no-op:\n%s", stdout)
+	}
+}
+
+func TestRebalance(t *testing.T) {
+	cluster, err := rcctest.NewCluster(
+		rcctest.Spec{Slots: []rcc.Slot{{Start: 0, End: 12287}}},
+		rcctest.Spec{Slots: []rcc.Slot{{Start: 12288, End: 16383}}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cluster.Close()
+	setKeys(t, cluster, 1000)
+	a, b := cluster.Servers[0], cluster.Servers[1]
+
+	planFile := filepath.Join(t.TempDir(), "plan.json")
+	mustRun(t, "rebalance", "--plan", planFile, a.Addr)
+	if got := cluster.Owner(0); got != a.ID {
+		t.Fatalf("rebalance --plan moved slot 0 to %s", got)
+	}
+
+	mustRun(t, "rebalance", a.Addr)
+	keysA, keysB := dbsize(t, a), dbsize(t, b)
+	if keysA+keysB != 1000 {
+		t.Errorf("masters have %d keys after rebalance, want 1000", keysA+keysB)
+	}
+	for _, n := range []int64{keysA, keysB} {
+		if n < 450 || n > 550 {
+			t.Errorf("masters have %d and %d keys after rebalance, want about 500", keysA, keysB)
+			break
+		}
+	}
+	stdout := mustRun(t, "rebalance", a.Addr)
+	if !strings.Contains(stdout, "balanced") {
+		t.Errorf("rebalance of balanced cluster is not no-op:\n%s", stdout)
+	}
+
+	// slot open on b is not shown by CLUSTER NODES of seed a
+	manager := rcc.NewManager(rcc.DefaultClientOptions)
+	defer manager.Close()
+	if _, err := manager.Client(b.Addr).(rcc.Commander).Do(context.Background(), "cluster", "setslot", "16000", "migrating", a.ID); err != nil {
+		t.Fatal(err)
+	}
+	code, _, stderr := runCommand(t, "rebalance", a.Addr)
+	if code == 0 || !strings.Contains(stderr, "Slot is importing or migrating") {
+		t.Errorf("rebalance with open slot exits %d:\n%s", code, stderr)
+	}
+}
+
+func TestSlotMemory(t *testing.T) {
+	cluster, err := rcctest.NewCluster(
+		rcctest.Spec{Slots: []rcc.Slot{{Start: 0, End: 8191}}},
+		rcctest.Spec{Slots: []rcc.Slot{{Start: 8192, End: 16383}}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cluster.Close()
+	a := cluster.Servers[0]
+	// 50 keys of slot 0 of different sizes, MEMORY USAGE of rcctest is key, value and 48 bytes
+	var total int64
+	for i := 0; i < 50; i++ {
+		key, value := fmt.Sprintf("{06S}%02d", i), strings.Repeat("v", i)
+		a.Set(key, value)
+		total += int64(len(key) + len(value) + 48)
+	}
+	estimate := func(args ...string) rcc.SlotMemory {
+		t.Helper()
+		var shards []shardMemory
+		stdout := mustRun(t, append(append([]string{"slot-memory", "--json"}, args...), a.Addr)...)
+		if err := json.Unmarshal([]byte(stdout), &shards); err != nil {
+			t.Fatalf("slot-memory --json prints %q: %v", stdout, err)
+		}
+		for _, shard := range shards {
+			if shard.ID != a.ID {
+				continue
+			}
+			for _, slot := range shard.Slots {
+				if slot.Slot == 0 {
+					return slot
+				}
+			}
+		}
+		t.Fatalf("slot-memory --json does not print slot 0:\n%s", stdout)
+		return rcc.SlotMemory{}
+	}
+
+	if got := estimate("--min-samples", "100"); got.Sampled != 50 || got.Bytes != total || got.Margin != 0 {
+		t.Errorf("slot 0 of every key measured = %+v, want %d bytes of margin 0", got, total)
+	}
+	if got := estimate("--min-samples", "10", "--max-samples", "10"); got.Sampled != 10 || got.Margin <= 0 {
+		t.Errorf("slot 0 of 10 keys measured = %+v, want margin of 10 samples", got)
+	}
+
+	stdout := mustRun(t, "slot-memory", "--rank", "1", "--min-samples", "100", a.Addr)
+	for _, want := range []string{"slot:    0 keys:      50 sampled:    50", fmt.Sprintf("total bytes:%d+-0", total)} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("slot-memory does not print %q:\n%s", want, stdout)
+		}
+	}
+}
+
+func TestKeys(t *testing.T) {
+	cluster, err := rcctest.NewCluster(
+		rcctest.Spec{Slots: []rcc.Slot{{Start: 0, End: 8191}}},
+		rcctest.Spec{Slots: []rcc.Slot{{Start: 8192, End: 16383}}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cluster.Close()
+	a, b := cluster.Servers[0], cluster.Servers[1]
+	var slot0 []string
+	for i := 0; i < 5; i++ {
+		key := fmt.Sprintf("{06S}%d", i)
+		a.Set(key, "v")
+		slot0 = append(slot0, key)
+	}
+	setKeys(t, cluster, 20)
+	var ofB []string
+	for i := 0; i < 20; i++ {
+		if key := fmt.Sprintf("key:%d", i); cluster.Owner(rcc.KeySlot(key)) == b.ID {
+			ofB = append(ofB, key)
+		}
+	}
+	lines := func(stdout string) []string {
+		got := strings.Fields(stdout)
+		sort.Strings(got)
+		return got
+	}
+
+	if got := lines(mustRun(t, "keys", "--slots", "0", a.Addr)); strings.Join(got, " ") != strings.Join(slot0, " ") {
+		t.Errorf("keys --slots 0 prints %v, want %v", got, slot0)
+	}
+	// hostname of node is resolved rather than compared with address in CLUSTER NODES
+	sort.Strings(ofB)
+	node := fmt.Sprintf("localhost:%d", b.Port)
+	if got := lines(mustRun(t, "keys", "--node", node, a.Addr)); strings.Join(got, " ") != strings.Join(ofB, " ") {
+		t.Errorf("keys --node %s prints %v, want %v", node, got, ofB)
+	}
+
+	code, stdout, stderr := runCommand(t, "keys", "--slots", "0", "--max-keys", "2", a.Addr)
+	if code != 0 || len(lines(stdout)) != 2 || !strings.Contains(stderr, "keys of slot over max are not read") {
+		t.Errorf("keys --max-keys 2 exits %d and prints %q, want 2 keys and warning:\n%s", code, stdout, stderr)
+	}
+
+	var key rcc.SlotKey
+	stdout = mustRun(t, "keys", "--slots", "0", "--limit", "1", "--json", a.Addr)
+	if err := json.Unmarshal([]byte(stdout), &key); err != nil || key.Slot != 0 || key.Type != "string" || key.PTTL == nil || *key.PTTL != -1 {
+		t.Errorf("keys --json prints %q, want string key of slot 0 without expire", stdout)
+	}
+}
+
+func TestReplication(t *testing.T) {
+	a, b := "a000000000000000000000000000000000000000", "b000000000000000000000000000000000000000"
+	cluster, err := rcctest.NewCluster(
+		rcctest.Spec{ID: a, Slots: []rcc.Slot{{Start: 0, End: 8191}}},
+		rcctest.Spec{SlaveOf: a},
+		rcctest.Spec{ID: b, Slots: []rcc.Slot{{Start: 8192, End: 16383}}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cluster.Close()
+	slave := cluster.Servers[1]
+	slave.SetReplication(0, 1, false)
+
+	// master without replicas still advances its offset
+	client := rcc.NewClient(cluster.Server(b).Addr, rcc.DefaultClientOptions)
+	defer client.Close()
+	for i := 0; ; i++ {
+		key := fmt.Sprintf("key:%d", i)
+		if cluster.Owner(rcc.KeySlot(key)) != b {
+			continue
+		}
+		if _, err := client.(rcc.Commander).Do(context.Background(), "set", key, "value"); err != nil {
+			t.Fatal(err)
+		}
+		break
+	}
+	resp, err := client.Info(context.Background(), "replication")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repl, err := rcc.ParseReplication(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repl.MasterReplOffset == 0 {
+		t.Fatal("offset of master is not advanced by write")
+	}
+
+	var report []rcc.MasterReplication
+	stdout := mustRun(t, "replication", "--json", "--interval", "100ms", cluster.Servers[0].Addr)
+	if err := json.Unmarshal([]byte(stdout), &report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report) != 2 {
+		t.Fatalf("replication reports %d masters, want 2:\n%s", len(report), stdout)
+	}
+	for _, master := range report {
+		switch master.Master.ID {
+		case a:
+			if len(master.Replicas) != 1 || master.Replicas[0].Replica.ID != slave.ID || !master.Replicas[0].Safe || master.Replicas[0].LastAckSeconds != 1 || master.Replicas[0].LagSeconds != 0 {
+				t.Errorf("replicas of %s = %+v, want safe %s acked 1s ago and not behind", a, master.Replicas, slave.ID)
+			}
+		case b:
+			if master.Offset != repl.MasterReplOffset || len(master.Replicas) != 0 {
+				t.Errorf("replication of %s = %+v, want offset %d without replicas", b, master, repl.MasterReplOffset)
+			}
+		}
+	}
+
+	stdout = mustRun(t, "replication", "--interval", "100ms", cluster.Servers[0].Addr)
+	if want := fmt.Sprintf("master_repl_offset:%d write_rate:0B/s\n", repl.MasterReplOffset); !strings.Contains(stdout, want) {
+		t.Errorf("replication does not print %q of master without replicas:\n%s", want, stdout)
+	}
+	if !strings.Contains(stdout, "lag:0B/0.0s last_ack:1s") {
+		t.Errorf("replication does not print lag and last ack of replica:\n%s", stdout)
+	}
+
+	slave.SetReplication(2<<20, 1, false)
+	if code, stdout, _ := runCommand(t, "replication", "--check", "--interval", "100ms", cluster.Servers[0].Addr); code == 0 || !strings.Contains(stdout, "UNSAFE") {
+		t.Errorf("replication --check of lagging replica exited with %d:\n%s", code, stdout)
+	}
+}
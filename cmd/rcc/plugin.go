@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// pluginPrefix is prefix of external subcommand executable, 'rcc foo' runs 'rcc-foo' found on PATH
+const pluginPrefix = "rcc-"
+
+// runPlugin run external subcommand and returns its exit code
+func runPlugin(name string, args []string) (code int, err error) {
+	path, err := exec.LookPath(pluginPrefix + name)
+	if err != nil {
+		err = errors.New(fmt.Sprintf("'%s' is not a %s command, see '%s --help'", name, App.Name, App.Name))
+		return 1, err
+	}
+
+	cmd := exec.Command(path, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			// plugin has already reported its own error
+			return exitErr.ExitCode(), nil
+		}
+		err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+		return 1, err
+	}
+	return 0, nil
+}
+
+// findPlugins returns subcommand names of executables found on PATH
+func findPlugins() (names []string) {
+	found := make(map[string]bool)
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, file := range files {
+			name := file.Name()
+			if !strings.HasPrefix(name, pluginPrefix) || file.IsDir() || file.Mode()&0111 == 0 {
+				continue
+			}
+			name = strings.TrimPrefix(name, pluginPrefix)
+			if found[name] || isBuiltin(name) {
+				continue
+			}
+			found[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func isBuiltin(name string) bool {
+	for _, c := range commands {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
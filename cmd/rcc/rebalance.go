@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/kizkoh/rcc/rcc"
+	"github.com/pkg/errors"
+)
+
+func runRebalance(ctx context.Context, g *Global, args []string) error {
+	var (
+		by        = "keys"
+		threshold = rcc.DefaultRebalanceThreshold * 100
+		count     = rcc.DefaultCountOptions
+		sample    = rcc.DefaultSampleOptions
+		rate      = rcc.DefaultSampleOptions.Rate * 100
+		planFile  = ""
+		help      = false
+	)
+
+	// parse args
+	flags := flag.NewFlagSet("rebalance", flag.ContinueOnError)
+
+	flags.StringVar(&by, "by", by, "by")
+	flags.Float64Var(&threshold, "threshold", threshold, "threshold")
+	flags.IntVar(&count.Batch, "pipeline", count.Batch, "pipeline")
+	flags.IntVar(&count.Workers, "parallel", count.Workers, "parallel")
+	flags.Float64Var(&rate, "sample-rate", rate, "sample-rate")
+	flags.StringVar(&planFile, "plan", planFile, "plan")
+	g.SetFlags(flags)
+	flags.BoolVar(&help, "h", help, "help")
+	flags.BoolVar(&help, "help", help, "help")
+
+	flags.Usage = func() { rebalanceUsage() }
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if help || flags.NArg() > 1 {
+		rebalanceUsage()
+		return nil
+	}
+
+	if err := g.InitLogger(); err != nil {
+		return err
+	}
+
+	if by != "keys" && by != "memory" {
+		err := errors.New(fmt.Sprintf("Unknown --by %q, keys or memory is expected", by))
+		return errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+	}
+
+	seeds, err := g.Seeds(flags.Args())
+	if err != nil {
+		return err
+	}
+	manager, err := g.Manager()
+	if err != nil {
+		return err
+	}
+	discovery, err := rcc.DiscoverContext(ctx, manager, seeds)
+	if err != nil {
+		return err
+	}
+
+	weights, err := rcc.CountKeysInSlots(ctx, manager, discovery.Nodes, count)
+	if err != nil {
+		return err
+	}
+	if by == "memory" {
+		sample.Rate, sample.Workers = rate/100, count.Workers
+		if weights, err = rcc.MemoryWeights(ctx, manager, discovery.Nodes, weights, sample); err != nil {
+			return err
+		}
+	}
+
+	// only node itself shows its open slots, so every master is asked
+	open, err := rcc.OpenSlots(ctx, manager, discovery.Nodes)
+	if err != nil {
+		return err
+	}
+	plan, err := rcc.RebalancePlan(discovery.Nodes, open, weights, threshold/100, discovery.Seed, g.CommandLine())
+	if err != nil {
+		return err
+	}
+	if len(plan.Steps) == 0 {
+		fmt.Printf("%s of masters are balanced within %g%%\n", by, threshold)
+		return nil
+	}
+	var slots int
+	for _, step := range plan.Steps {
+		start, end, err := rcc.ParseSlotRange(step.Slots)
+		if err != nil {
+			return err
+		}
+		slots += end - start + 1
+	}
+	if planFile != "" {
+		if err := plan.Write(planFile); err != nil {
+			return err
+		}
+		fmt.Printf("plan moving %d slots in %d steps is written to %s, run '%s apply %s' to rebalance\n", slots, len(plan.Steps), planFile, App.Name, planFile)
+		return nil
+	}
+	return g.audit(ctx, discovery.Seed, func() error {
+		if err := plan.Apply(ctx, manager); err != nil {
+			return err
+		}
+		fmt.Printf("%d slots are moved in %d steps\n", slots, len(plan.Steps))
+		return nil
+	})
+}
+
+func rebalanceUsage() {
+	helpText := `
+usage:
+   {{.Name}} [command options] [<HOST:PORT>[,<HOST:PORT>...]]
+
+version:
+   {{.Version}}
+
+author:
+   kizkoh<GitHub: https://github.com/kizkoh>
+
+options:
+   --by <keys|memory>                           Balance keys or estimated memory of masters (default: keys)
+   --threshold <PERCENT>                        Leave master within percent of mean as is (default: 2)
+   --pipeline <N>                               Slots counted in one round trip (default: 1000)
+   --parallel <N>                               Shards counted concurrently (default: 8)
+   --sample-rate <PERCENT>                      Percent of keys of slot measured with --by memory (default: 1)
+   --plan <FILE>                                Write plan to file instead of rebalancing, run it by apply
+   --help, -h                                   Show help
+
+global options:
+{{.GlobalOptions}}
+`
+	printUsage(App.Name+" rebalance", helpText)
+}
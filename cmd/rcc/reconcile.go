@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/kizkoh/rcc/rcc"
+)
+
+func runReconcile(ctx context.Context, g *Global, args []string) error {
+	var planFile = ""
+	var help = false
+
+	// parse args
+	flags := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+
+	flags.StringVar(&planFile, "plan", planFile, "plan")
+	g.SetFlags(flags)
+	flags.BoolVar(&help, "h", help, "help")
+	flags.BoolVar(&help, "help", help, "help")
+
+	flags.Usage = func() { reconcileUsage() }
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if help || flags.NArg() == 0 {
+		reconcileUsage()
+		return nil
+	}
+
+	if err := g.InitLogger(); err != nil {
+		return err
+	}
+
+	desired, err := rcc.LoadDesiredState(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	seeds, err := g.Seeds(flags.Args()[1:])
+	if err != nil {
+		return err
+	}
+	manager, err := g.Manager()
+	if err != nil {
+		return err
+	}
+	discovery, err := rcc.DiscoverContext(ctx, manager, seeds)
+	if err != nil {
+		return err
+	}
+
+	plan, err := rcc.ReconcilePlan(ctx, manager, desired, discovery.Seed, g.CommandLine())
+	if err != nil {
+		return err
+	}
+	if len(plan.Steps) == 0 {
+		fmt.Println("cluster is in desired state")
+		return nil
+	}
+	if planFile != "" {
+		if err := plan.Write(planFile); err != nil {
+			return err
+		}
+		fmt.Printf("plan of %d steps is written to %s, run '%s apply %s' to reconcile\n", len(plan.Steps), planFile, App.Name, planFile)
+		return nil
+	}
+	return g.audit(ctx, discovery.Seed, func() error {
+		if err := plan.Apply(ctx, manager); err != nil {
+			return err
+		}
+		fmt.Printf("cluster is reconciled in %d steps\n", len(plan.Steps))
+		return nil
+	})
+}
+
+func reconcileUsage() {
+	helpText := `
+usage:
+   {{.Name}} [command options] <DESIRED STATE FILE> [HOST:PORT...]
+
+version:
+   {{.Version}}
+
+author:
+   kizkoh<GitHub: https://github.com/kizkoh>
+
+options:
+   --plan <FILE>                                Write plan to file instead of reconciling, run it by apply
+   --help, -h                                   Show help
+
+global options:
+{{.GlobalOptions}}
+`
+	printUsage(App.Name+" reconcile", helpText)
+}
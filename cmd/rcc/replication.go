@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/kizkoh/rcc/rcc"
+	"github.com/pkg/errors"
+)
+
+func runReplication(ctx context.Context, g *Global, args []string) error {
+	var (
+		opt    = rcc.DefaultLagOptions
+		check  = false
+		asJSON = false
+		help   = false
+	)
+
+	// parse args
+	flags := flag.NewFlagSet("replication", flag.ContinueOnError)
+
+	flags.Int64Var(&opt.MaxBytes, "max-lag-bytes", opt.MaxBytes, "max-lag-bytes")
+	flags.Int64Var(&opt.MaxSeconds, "max-lag-seconds", opt.MaxSeconds, "max-lag-seconds")
+	flags.DurationVar(&opt.Interval, "interval", opt.Interval, "interval")
+	flags.BoolVar(&check, "check", check, "check")
+	flags.BoolVar(&asJSON, "json", asJSON, "json")
+	g.SetFlags(flags)
+	flags.BoolVar(&help, "h", help, "help")
+	flags.BoolVar(&help, "help", help, "help")
+
+	flags.Usage = func() { replicationUsage() }
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if help || flags.NArg() > 1 {
+		replicationUsage()
+		return nil
+	}
+
+	if err := g.InitLogger(); err != nil {
+		return err
+	}
+
+	cluster, err := g.clusterNodes(ctx, "", flags.Args())
+	if err != nil {
+		return err
+	}
+	manager, err := g.Manager()
+	if err != nil {
+		return err
+	}
+	report, err := rcc.ReplicationReport(ctx, manager, cluster, opt)
+	if err != nil {
+		return err
+	}
+
+	unsafe, replicas := 0, 0
+	for _, master := range report {
+		for _, lag := range master.Replicas {
+			replicas++
+			if !lag.Safe {
+				unsafe++
+			}
+		}
+	}
+	if asJSON {
+		if err := json.NewEncoder(os.Stdout).Encode(report); err != nil {
+			return err
+		}
+	} else {
+		printReplication(g, cluster, report)
+	}
+	if check && unsafe > 0 {
+		err := errors.New(fmt.Sprintf("%d of %d replicas are not safe to fail over to", unsafe, replicas))
+		return errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+	}
+	return nil
+}
+
+// printReplication print masters with their offsets, and their slaves with lag as tree
+func printReplication(g *Global, cluster []rcc.ClusterNode, report []rcc.MasterReplication) {
+	byID := make(map[string]rcc.ClusterNode)
+	for _, node := range cluster {
+		byID[node.ID] = node
+	}
+	for i, master := range report {
+		last := i == len(report)-1
+		if last {
+			fmt.Print("└─ ")
+		} else {
+			fmt.Print("├─ ")
+		}
+		node, ok := byID[master.Master.ID]
+		host, _ := g.nodeLabel(node)
+		if !ok {
+			host = "-"
+		}
+		fmt.Printf("%s %s:%d master_repl_offset:", master.Master.ID, host, node.Port)
+		if master.Offset < 0 {
+			fmt.Print("-")
+			if master.Error != "" {
+				fmt.Printf(" (%s)", master.Error)
+			}
+		} else {
+			fmt.Printf("%d", master.Offset)
+		}
+		if master.Rate >= 0 {
+			fmt.Printf(" write_rate:%.0fB/s", master.Rate)
+		}
+		fmt.Print("\n")
+
+		for j, lag := range master.Replicas {
+			if last {
+				fmt.Print("    ")
+			} else {
+				fmt.Print("│  ")
+			}
+			if j == len(master.Replicas)-1 {
+				fmt.Print("└── ")
+			} else {
+				fmt.Print("├── ")
+			}
+			slave := byID[lag.Replica.ID]
+			host, _ := g.nodeLabel(slave)
+			fmt.Printf("%s %s:%d ", slave.ID, host, slave.Port)
+			fmt.Printf("offset:%d lag:%dB/%s last_ack:%s link:%s last_io:%s ", lag.Offset, lag.LagBytes, lagSeconds(lag.LagSeconds), seconds(lag.LastAckSeconds), lag.LinkStatus, seconds(lag.LastIOSeconds))
+			if lag.Safe {
+				fmt.Print("ok")
+			} else {
+				fmt.Printf("UNSAFE (%s)", lag.Reason)
+			}
+			fmt.Print("\n")
+		}
+	}
+}
+
+// seconds returns seconds, or "-" for unknown
+func seconds(s int64) string {
+	if s < 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%ds", s)
+}
+
+// lagSeconds returns estimated seconds behind master, or "-" for unknown
+func lagSeconds(s float64) string {
+	if s < 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1fs", s)
+}
+
+func replicationUsage() {
+	helpText := `
+usage:
+   {{.Name}} [command options] [<HOST:PORT>[,<HOST:PORT>...]]
+
+version:
+   {{.Version}}
+
+author:
+   kizkoh<GitHub: https://github.com/kizkoh>
+
+options:
+   --max-lag-bytes <N>                          Replica further behind master is unsafe to fail over to (default: 1048576)
+   --max-lag-seconds <N>                        Replica behind, or without ACK or I/O, for longer seconds is unsafe to fail over to (default: 10)
+   --interval <DURATION>                        Time between two reads of master offset timing lag of replicas (default: 1s)
+   --check                                      Exit with error when any replica is unsafe
+   --json                                       Print offset of every master and replication of its replicas as JSON
+   --help, -h                                   Show help
+
+global options:
+{{.GlobalOptions}}
+`
+	printUsage(App.Name+" replication", helpText)
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/kizkoh/rcc/rcc"
+)
+
+// shardMemory is estimated bytes of master and slots owned by it
+type shardMemory struct {
+	ID      string           `json:"id"`
+	Addr    string           `json:"addr"`
+	Host    string           `json:"host"`
+	Flags   []string         `json:"flags"`
+	Keys    int64            `json:"keys"`
+	Sampled int              `json:"sampled"`
+	Bytes   int64            `json:"bytes"`
+	Margin  int64            `json:"margin"`
+	Slots   []rcc.SlotMemory `json:"slots"`
+}
+
+func runSlotMemory(ctx context.Context, g *Global, args []string) error {
+	var (
+		rank   = 0
+		rate   = rcc.DefaultSampleOptions.Rate * 100
+		sample = rcc.DefaultSampleOptions
+		count  = rcc.DefaultCountOptions
+		asJSON = false
+		help   = false
+	)
+
+	// parse args
+	flags := flag.NewFlagSet("slot-memory", flag.ContinueOnError)
+
+	flags.IntVar(&rank, "rank", rank, "rank")
+	flags.Float64Var(&rate, "sample-rate", rate, "sample-rate")
+	flags.IntVar(&sample.Min, "min-samples", sample.Min, "min-samples")
+	flags.IntVar(&sample.Max, "max-samples", sample.Max, "max-samples")
+	flags.IntVar(&count.Batch, "pipeline", count.Batch, "pipeline")
+	flags.IntVar(&count.Workers, "parallel", count.Workers, "parallel")
+	flags.BoolVar(&asJSON, "json", asJSON, "json")
+	g.SetFlags(flags)
+	flags.BoolVar(&help, "h", help, "help")
+	flags.BoolVar(&help, "help", help, "help")
+
+	flags.Usage = func() { slotMemoryUsage() }
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if help || flags.NArg() > 1 {
+		slotMemoryUsage()
+		return nil
+	}
+
+	if err := g.InitLogger(); err != nil {
+		return err
+	}
+
+	nodes, err := g.clusterNodes(ctx, "", flags.Args())
+	if err != nil {
+		return err
+	}
+	manager, err := g.Manager()
+	if err != nil {
+		return err
+	}
+
+	counts, err := rcc.CountKeysInSlots(ctx, manager, nodes, count)
+	if err != nil && ctx.Err() != nil {
+		return err
+	}
+	if err != nil {
+		rcc.DefaultLogger.Warn("keys are partially counted", "error", err)
+	}
+	sample.Rate, sample.Workers = rate/100, count.Workers
+	slots, err := rcc.EstimateMemory(ctx, manager, nodes, counts, sample)
+	if err != nil && ctx.Err() != nil {
+		return err
+	}
+	if err != nil {
+		rcc.DefaultLogger.Warn("memory is partially estimated", "error", err)
+	}
+
+	owners := rcc.SlotOwners(nodes)
+	unknown := 0
+	for slot, id := range owners {
+		if id != "" && slots[slot].Keys > 0 && slots[slot].Bytes < 0 {
+			unknown++
+		}
+	}
+	if unknown > 0 {
+		rcc.DefaultLogger.Warn("slots of vanished or unreadable keys are not estimated", "slots", unknown)
+	}
+
+	shards := []shardMemory{}
+	for _, node := range nodes {
+		if node.Slave || node.HasFlag("fail") || node.HasFlag("noaddr") {
+			continue
+		}
+		shard := shardMemory{ID: node.ID, Addr: node.Addr(), Host: node.Host, Flags: node.Flags, Slots: []rcc.SlotMemory{}}
+		for slot, id := range owners {
+			if id != node.ID || slots[slot].Bytes < 0 {
+				continue
+			}
+			shard.Slots = append(shard.Slots, slots[slot])
+			shard.Keys += slots[slot].Keys
+			shard.Sampled += slots[slot].Sampled
+		}
+		if len(shard.Slots) == 0 {
+			continue
+		}
+		shard.Bytes = rcc.SumSlotMemory(shard.Slots)
+		shard.Margin = rcc.MarginSlotMemory(shard.Slots)
+		shards = append(shards, shard)
+	}
+
+	if asJSON {
+		return json.NewEncoder(os.Stdout).Encode(shards)
+	}
+	for _, shard := range shards {
+		printShardMemory(shard, rank)
+	}
+	fmt.Printf("total bytes:%d+-%s\n", rcc.SumSlotMemory(slots), formatMargin(rcc.MarginSlotMemory(slots)))
+	return nil
+}
+
+// printShardMemory print estimated bytes of shard, and top rank slots of most bytes
+func printShardMemory(shard shardMemory, rank int) {
+	_, port, _ := net.SplitHostPort(shard.Addr)
+	fmt.Printf("%s %s:%s ", shard.ID, shard.Host, port)
+	fmt.Printf("%-16s", "["+strings.Join(shard.Flags, ",")+"]")
+	fmt.Printf("slots:%5d keys:%8d sampled:%6d ", len(shard.Slots), shard.Keys, shard.Sampled)
+	fmt.Printf("bytes:%12d+-%s", shard.Bytes, formatMargin(shard.Margin))
+	fmt.Print("\n")
+
+	ranking := append([]rcc.SlotMemory(nil), shard.Slots...)
+	sort.SliceStable(ranking, func(i, j int) bool { return ranking[i].Bytes > ranking[j].Bytes })
+	for i, slot := range ranking {
+		if i >= rank {
+			break
+		}
+		fmt.Printf("slot:%5d keys:%8d sampled:%6d bytes:%12d+-%s\n", slot.Slot, slot.Keys, slot.Sampled, slot.Bytes, formatMargin(slot.Margin))
+	}
+}
+
+// formatMargin formats margin of estimated bytes, unknown margin is '?'
+func formatMargin(margin int64) string {
+	if margin < 0 {
+		return "?"
+	}
+	return fmt.Sprint(margin)
+}
+
+func slotMemoryUsage() {
+	helpText := `
+usage:
+   {{.Name}} [command options] [<HOST:PORT>[,<HOST:PORT>...]]
+
+version:
+   {{.Version}}
+
+author:
+   kizkoh<GitHub: https://github.com/kizkoh>
+
+options:
+   --rank <N>                                   Print N slots of most bytes of every shard
+   --sample-rate <PERCENT>                      Percent of keys of slot measured by MEMORY USAGE (default: 1)
+   --min-samples <N>                            Keys measured at least in slot (default: 20)
+   --max-samples <N>                            Keys measured at most in slot (default: 1000)
+   --pipeline <N>                               Slots counted in one round trip (default: 1000)
+   --parallel <N>                               Shards counted and sampled concurrently (default: 8)
+   --json                                       Print estimate and 95% margin of every slot and shard as JSON
+   --help, -h                                   Show help
+
+global options:
+{{.GlobalOptions}}
+`
+	printUsage(App.Name+" slot-memory", helpText)
+}
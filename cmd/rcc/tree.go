@@ -1,74 +1,42 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
-	"log"
-	"os"
-	"strings"
-	"text/template"
-
-	"github.com/go-redis/redis"
-	"github.com/kizkoh/rcc/rcc"
-	"github.com/pkg/errors"
 )
 
-// debug is extended bool and output debug message
-type debug bool
-
-func (debug debug) Printf(f string, v ...interface{}) {
-	if debug {
-		log.Printf(f, v...)
-	}
-}
-
-// DEBUG is global debug type
-var DEBUG debug
-
-func main() {
+func runTree(ctx context.Context, g *Global, args []string) error {
 	var masterOnly = false
+	var fromFile = ""
 	var help = false
-	var verbose = false
 
 	// parse args
-	flags := flag.NewFlagSet(App.Name, flag.ContinueOnError)
+	flags := flag.NewFlagSet("tree", flag.ContinueOnError)
 
 	flags.BoolVar(&masterOnly, "master", masterOnly, "master")
-	flags.BoolVar(&verbose, "verbose", verbose, "verbose")
+	flags.StringVar(&fromFile, "from-file", fromFile, "from-file")
+	g.SetFlags(flags)
 	flags.BoolVar(&help, "h", help, "help")
 	flags.BoolVar(&help, "help", help, "help")
-	flags.BoolVar(&help, "version", help, "version")
 
-	flags.Usage = func() { usage() }
-	if err := flags.Parse(os.Args[1:]); err != nil {
-		err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
-		fmt.Printf("%v-%v failed: %v\n", App.Name, App.Version, err)
-		os.Exit(1)
+	flags.Usage = func() { treeUsage() }
+	if err := flags.Parse(args); err != nil {
+		return err
 	}
 
 	if help {
-		usage()
-		os.Exit(0)
+		treeUsage()
+		return nil
 	}
 
-	DEBUG = debug(verbose)
-
-	args := flags.Args()
-	var arg string
-	if len(args) == 0 {
-		arg = "127.0.0.1:6379"
-	} else {
-		arg = args[len(args)-1]
+	if err := g.InitLogger(); err != nil {
+		return err
 	}
 
-	client := redis.NewClient(&redis.Options{
-		Addr: arg,
-	})
-	cluster, err := rcc.ClusterNodes(client)
+	cluster, err := g.clusterNodes(ctx, fromFile, flags.Args())
 	if err != nil {
-		err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
-		fmt.Printf("%v-%v failed: %v\n", App.Name, App.Version, err)
-		os.Exit(1)
+		return err
 	}
 
 	nmaster := 0
@@ -85,7 +53,11 @@ func main() {
 			} else {
 				fmt.Print("└─ ")
 			}
-			fmt.Printf("%s %s:%d ", master.ID, master.Host, master.Port)
+			host, zone := g.nodeLabel(master)
+			fmt.Printf("%s %s:%d ", master.ID, host, master.Port)
+			if zone != "" {
+				fmt.Printf("zone:%s ", zone)
+			}
 			fmt.Print("[")
 			for i, flag := range master.Flags {
 				if len(master.Flags)-1 != i {
@@ -121,7 +93,11 @@ func main() {
 							} else {
 								fmt.Print("└── ")
 							}
-							fmt.Printf("%s %s:%d ", slave.ID, slave.Host, slave.Port)
+							host, zone := g.nodeLabel(slave)
+							fmt.Printf("%s %s:%d ", slave.ID, host, slave.Port)
+							if zone != "" {
+								fmt.Printf("zone:%s ", zone)
+							}
 							fmt.Print("[")
 							for i, flag := range slave.Flags {
 								if len(slave.Flags)-1 != i {
@@ -140,12 +116,13 @@ func main() {
 		}
 	}
 
+	return nil
 }
 
-func usage() {
+func treeUsage() {
 	helpText := `
 usage:
-   {{.Name}} [command options]
+   {{.Name}} [command options] [<HOST:PORT>[,<HOST:PORT>...]]
 
 version:
    {{.Version}}
@@ -154,12 +131,12 @@ author:
    kizkoh<GitHub: https://github.com/kizkoh>
 
 options:
-   --verbose                                    Print verbose messages
+   --master                                     Print only masters
+   --from-file <FILE>                           Read nodes.conf or saved CLUSTER NODES output instead of connecting
    --help, -h                                   Show help
-   --version                                    Print the version
+
+global options:
+{{.GlobalOptions}}
 `
-	t := template.New("usage")
-	t, _ = t.Parse(strings.TrimSpace(helpText))
-	t.Execute(os.Stdout, App)
-	fmt.Println()
+	printUsage(App.Name+" tree", helpText)
 }
@@ -1,72 +1,42 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
-	"log"
-	"os"
-	"strings"
-	"text/template"
 
-	"github.com/go-redis/redis"
 	"github.com/kizkoh/rcc/rcc"
-	"github.com/pkg/errors"
 )
 
-// debug is extended bool and output debug message
-type debug bool
-
-func (debug debug) Printf(f string, v ...interface{}) {
-	if debug {
-		log.Printf(f, v...)
-	}
-}
-
-// DEBUG is global debug type
-var DEBUG debug
-
-func main() {
+func runWhoami(ctx context.Context, g *Global, args []string) error {
+	var fromFile = ""
 	var help = false
-	var verbose = false
 
 	// parse args
-	flags := flag.NewFlagSet(App.Name, flag.ContinueOnError)
+	flags := flag.NewFlagSet("whoami", flag.ContinueOnError)
 
-	flags.BoolVar(&verbose, "verbose", verbose, "verbose")
+	flags.StringVar(&fromFile, "from-file", fromFile, "from-file")
+	g.SetFlags(flags)
 	flags.BoolVar(&help, "h", help, "help")
 	flags.BoolVar(&help, "help", help, "help")
-	flags.BoolVar(&help, "version", help, "version")
 
-	flags.Usage = func() { usage() }
-	if err := flags.Parse(os.Args[1:]); err != nil {
-		err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
-		fmt.Printf("%v-%v failed: %v\n", App.Name, App.Version, err)
-		os.Exit(1)
+	flags.Usage = func() { whoamiUsage() }
+	if err := flags.Parse(args); err != nil {
+		return err
 	}
 
 	if help {
-		usage()
-		os.Exit(0)
+		whoamiUsage()
+		return nil
 	}
 
-	DEBUG = debug(verbose)
-
-	args := flags.Args()
-	var arg string
-	if len(args) == 0 {
-		arg = "127.0.0.1:6379"
-	} else {
-		arg = args[len(args)-1]
+	if err := g.InitLogger(); err != nil {
+		return err
 	}
 
-	client := redis.NewClient(&redis.Options{
-		Addr: arg,
-	})
-	cluster, err := rcc.ClusterNodes(client)
+	cluster, err := g.nodeClusterNodes(ctx, fromFile, flags.Args())
 	if err != nil {
-		err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
-		fmt.Printf("%v-%v failed: %v\n", App.Name, App.Version, err)
-		os.Exit(1)
+		return err
 	}
 
 	var myself rcc.ClusterNode
@@ -80,7 +50,11 @@ func main() {
 
 	fmt.Printf("myself:\n")
 	fmt.Printf("  id: %s\n", myself.ID)
-	fmt.Printf("  host: %s\n", myself.Host)
+	host, zone := g.nodeLabel(myself)
+	fmt.Printf("  host: %s\n", host)
+	if zone != "" {
+		fmt.Printf("  zone: %s\n", zone)
+	}
 	fmt.Printf("  port: %d\n", myself.Port)
 	fmt.Printf("  flag: ")
 	for i, flag := range myself.Flags {
@@ -95,7 +69,11 @@ func main() {
 		for _, node := range cluster {
 			if node.SlaveOf == myself.ID {
 				fmt.Printf("  - id: %s\n", node.ID)
-				fmt.Printf("    host: %s\n", node.Host)
+				host, zone := g.nodeLabel(node)
+				fmt.Printf("    host: %s\n", host)
+				if zone != "" {
+					fmt.Printf("    zone: %s\n", zone)
+				}
 				fmt.Printf("    port: %d\n", node.Port)
 				fmt.Printf("    flag: ")
 				for i, flag := range node.Flags {
@@ -113,7 +91,11 @@ func main() {
 		for _, node := range cluster {
 			if node.ID == myself.SlaveOf {
 				fmt.Printf("  - id: %s\n", node.ID)
-				fmt.Printf("    host: %s\n", node.Host)
+				host, zone := g.nodeLabel(node)
+				fmt.Printf("    host: %s\n", host)
+				if zone != "" {
+					fmt.Printf("    zone: %s\n", zone)
+				}
 				fmt.Printf("    port: %d\n", node.Port)
 				fmt.Printf("    flag: ")
 				for i, flag := range node.Flags {
@@ -126,12 +108,13 @@ func main() {
 			}
 		}
 	}
+	return nil
 }
 
-func usage() {
+func whoamiUsage() {
 	helpText := `
 usage:
-   {{.Name}} [command options]
+   {{.Name}} [command options] [<HOST:PORT>]
 
 version:
    {{.Version}}
@@ -140,12 +123,11 @@ author:
    kizkoh<GitHub: https://github.com/kizkoh>
 
 options:
-   --verbose                                    Print verbose messages
+   --from-file <FILE>                           Read nodes.conf or saved CLUSTER NODES output instead of connecting
    --help, -h                                   Show help
-   --version                                    Print the version
+
+global options:
+{{.GlobalOptions}}
 `
-	t := template.New("usage")
-	t, _ = t.Parse(strings.TrimSpace(helpText))
-	t.Execute(os.Stdout, App)
-	fmt.Println()
+	printUsage(App.Name+" whoami", helpText)
 }
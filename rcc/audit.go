@@ -0,0 +1,195 @@
+package rcc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// AuditRecord is record of mutating command appended to audit log as JSON line
+type AuditRecord struct {
+	Time     time.Time      `json:"time"`
+	Operator string         `json:"operator"`
+	Cluster  string         `json:"cluster"`
+	Command  string         `json:"command"`
+	Commands []AuditCommand `json:"commands"`
+	Before   string         `json:"before"`
+	After    string         `json:"after"`
+	Error    string         `json:"error,omitempty"`
+}
+
+// AuditCommand is mutating redis command sent to node
+type AuditCommand struct {
+	Time  time.Time `json:"time"`
+	Node  string    `json:"node"`
+	Cmd   string    `json:"cmd"`
+	Error string    `json:"error,omitempty"`
+}
+
+// AuditWriter appends audit record to audit log
+type AuditWriter interface {
+	WriteAudit(record AuditRecord) error
+	Close() error
+}
+
+// Audit collects mutating commands sent by clients while DefaultAudit is set
+type Audit struct {
+	mu     sync.Mutex
+	record AuditRecord
+}
+
+// DefaultAudit is audit collecting commands sent by every client, nil disables audit
+var DefaultAudit *Audit
+
+// NewAudit returns audit of rcc command line run by operator against cluster
+func NewAudit(operator string, cluster string, command string) *Audit {
+	return &Audit{record: AuditRecord{
+		Time:     time.Now(),
+		Operator: operator,
+		Cluster:  cluster,
+		Command:  command,
+		Commands: []AuditCommand{},
+	}}
+}
+
+// Command appends command sent to node
+func (a *Audit) Command(node string, cmd string, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	c := AuditCommand{Time: time.Now(), Node: node, Cmd: cmd}
+	if err != nil {
+		c.Error = err.Error()
+	}
+	a.record.Commands = append(a.record.Commands, c)
+}
+
+// Before sets CLUSTER NODES of client as topology before audited command
+func (a *Audit) Before(ctx context.Context, client Client) error {
+	return a.snapshot(ctx, client, &a.record.Before)
+}
+
+// After sets CLUSTER NODES of client as topology after audited command
+func (a *Audit) After(ctx context.Context, client Client) error {
+	return a.snapshot(ctx, client, &a.record.After)
+}
+
+func (a *Audit) snapshot(ctx context.Context, client Client, topology *string) error {
+	val, err := client.ClusterNodes(ctx)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	*topology = val
+	return nil
+}
+
+// Record returns collected record, err is error of audited command
+func (a *Audit) Record(err error) AuditRecord {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	record := a.record
+	record.Commands = append([]AuditCommand(nil), a.record.Commands...)
+	if err != nil {
+		record.Error = err.Error()
+	}
+	return record
+}
+
+// mutatingCommands is commands changing cluster state, other commands are not audited
+var mutatingCommands = map[string]bool{
+	"cluster addslots":         true,
+	"cluster addslotsrange":    true,
+	"cluster bumpepoch":        true,
+	"cluster delslots":         true,
+	"cluster delslotsrange":    true,
+	"cluster failover":         true,
+	"cluster flushslots":       true,
+	"cluster forget":           true,
+	"cluster meet":             true,
+	"cluster replicate":        true,
+	"cluster reset":            true,
+	"cluster set-config-epoch": true,
+	"cluster setslot":          true,
+	"config set":               true,
+	"config rewrite":           true,
+	"del":                      true,
+	"flushall":                 true,
+	"flushdb":                  true,
+	"migrate":                  true,
+	"replicaof":                true,
+	"restore":                  true,
+	"slaveof":                  true,
+	"unlink":                   true,
+}
+
+// IsMutatingCommand reports whether command args change cluster state
+func IsMutatingCommand(args []interface{}) bool {
+	if len(args) == 0 {
+		return false
+	}
+	name := strings.ToLower(fmt.Sprint(args[0]))
+	if mutatingCommands[name] {
+		return true
+	}
+	if len(args) > 1 {
+		return mutatingCommands[name+" "+strings.ToLower(fmt.Sprint(args[1]))]
+	}
+	return false
+}
+
+// OpenAuditLog opens audit log, dest is file path or "syslog" optionally followed by ":TAG"
+func OpenAuditLog(dest string) (AuditWriter, error) {
+	if dest == "syslog" || strings.HasPrefix(dest, "syslog:") {
+		tag := strings.TrimPrefix(strings.TrimPrefix(dest, "syslog"), ":")
+		if tag == "" {
+			tag = App.Name
+		}
+		return openSyslog(tag)
+	}
+	f, err := os.OpenFile(dest, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+		return nil, err
+	}
+	return &fileAuditWriter{f: f}, nil
+}
+
+// fileAuditWriter appends audit record to file
+type fileAuditWriter struct {
+	f *os.File
+}
+
+func (w *fileAuditWriter) WriteAudit(record AuditRecord) error {
+	b, err := json.Marshal(record)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+	}
+	// single write of line keeps records of concurrent rcc processes apart with O_APPEND
+	if _, err := w.f.Write(append(b, '\n')); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+	}
+	return nil
+}
+
+func (w *fileAuditWriter) Close() error {
+	return w.f.Close()
+}
+
+// Operator returns operator name, $RCC_OPERATOR, $SUDO_USER or login user in this order
+func Operator() string {
+	if name := firstEnv("RCC_OPERATOR", "SUDO_USER"); name != "" {
+		return name
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
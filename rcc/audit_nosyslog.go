@@ -0,0 +1,15 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package rcc
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+func openSyslog(tag string) (AuditWriter, error) {
+	err := errors.New("Syslog audit log is not supported on this platform")
+	return nil, errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+}
@@ -0,0 +1,41 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package rcc
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/syslog"
+
+	"github.com/pkg/errors"
+)
+
+// syslogAuditWriter sends audit record to local syslog with facility auth
+type syslogAuditWriter struct {
+	w *syslog.Writer
+}
+
+func openSyslog(tag string) (AuditWriter, error) {
+	w, err := syslog.New(syslog.LOG_AUTH|syslog.LOG_NOTICE, tag)
+	if err != nil {
+		err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+		return nil, err
+	}
+	return &syslogAuditWriter{w: w}, nil
+}
+
+func (w *syslogAuditWriter) WriteAudit(record AuditRecord) error {
+	b, err := json.Marshal(record)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+	}
+	if err := w.w.Notice(string(b)); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+	}
+	return nil
+}
+
+func (w *syslogAuditWriter) Close() error {
+	return w.w.Close()
+}
@@ -0,0 +1,190 @@
+package rcc
+
+import (
+	"math"
+	"sort"
+)
+
+// Balance is balance of slots, keys and used memory across masters
+type Balance struct {
+	Slots       Spread       `json:"slots"`
+	Keys        Spread       `json:"keys"`
+	UsedMemory  Spread       `json:"used_memory"`
+	Overloaded  string       `json:"overloaded"`  // ID of master of most keys
+	Underloaded string       `json:"underloaded"` // ID of master of least keys
+	Move        *BalanceMove `json:"move,omitempty"`
+}
+
+// Spread is spread of value across masters
+type Spread struct {
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"stddev"` // population standard deviation
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	Ratio  float64 `json:"ratio"` // max/min, 0 when min is 0
+	MinID  string  `json:"min_id"`
+	MaxID  string  `json:"max_id"`
+}
+
+// BalanceMove is move of slot range reducing standard deviation of keys the most
+type BalanceMove struct {
+	Slots       string  `json:"slots"` // slot range such as "100-200" or single slot
+	Keys        int64   `json:"keys"`
+	From        NodeRef `json:"from"`
+	To          NodeRef `json:"to"`
+	StdDevAfter float64 `json:"stddev_after"` // standard deviation of keys after move
+}
+
+// NewBalance returns balance of shards, keys are counted in slots
+//
+// Keys x moved from master of a keys to master of b keys reduce sum of squared deviations by 2x(a-b-x),
+// which is largest for x of (a-b)/2. Moved keys come in contiguous ranges of slots, so that most and least loaded
+// masters may have no range reducing it, such as master of a single hot slot. Every pair of donor and receiver is
+// tried with range of donor closest to half of their difference, and the move of largest reduction is taken.
+func NewBalance(shards []ShardStats) Balance {
+	var balance Balance
+	if len(shards) == 0 {
+		return balance
+	}
+	slots := make([]float64, len(shards))
+	keys := make([]float64, len(shards))
+	memory := make([]float64, len(shards))
+	for i, shard := range shards {
+		slots[i] = float64(shard.Slots)
+		keys[i] = float64(shard.SlotKeysSum())
+		memory[i] = float64(shard.UsedMemory)
+	}
+	balance.Slots = newSpread(shards, slots)
+	balance.Keys = newSpread(shards, keys)
+	balance.UsedMemory = newSpread(shards, memory)
+	balance.Overloaded, balance.Underloaded = balance.Keys.MaxID, balance.Keys.MinID
+	if balance.Overloaded == balance.Underloaded {
+		return balance
+	}
+
+	var best int64
+	var from, to, start, end int
+	var moved int64
+	for i := range shards {
+		for j := range shards {
+			diff := int64(keys[i]) - int64(keys[j])
+			if diff <= 1 {
+				continue
+			}
+			s, e, x := closestRange(shards[i].SlotKeys, diff/2)
+			// move of diff keys or more does not reduce imbalance
+			if x <= 0 || x >= diff {
+				continue
+			}
+			if gain := 2 * x * (diff - x); gain > best {
+				best, from, to, start, end, moved = gain, i, j, s, e, x
+			}
+		}
+	}
+	if best == 0 {
+		return balance
+	}
+	keys[from] -= float64(moved)
+	keys[to] += float64(moved)
+	balance.Move = &BalanceMove{
+		Slots:       FormatSlotRange(start, end),
+		Keys:        moved,
+		From:        NodeRef{ID: shards[from].ID, Addr: shards[from].Addr},
+		To:          NodeRef{ID: shards[to].ID, Addr: shards[to].Addr},
+		StdDevAfter: newSpread(shards, keys).StdDev,
+	}
+	return balance
+}
+
+func newSpread(shards []ShardStats, values []float64) (spread Spread) {
+	var sum float64
+	for i, v := range values {
+		sum += v
+		if i == 0 || v < spread.Min {
+			spread.Min, spread.MinID = v, shards[i].ID
+		}
+		if i == 0 || v > spread.Max {
+			spread.Max, spread.MaxID = v, shards[i].ID
+		}
+	}
+	spread.Mean = sum / float64(len(values))
+	var sq float64
+	for _, v := range values {
+		sq += (v - spread.Mean) * (v - spread.Mean)
+	}
+	spread.StdDev = math.Sqrt(sq / float64(len(values)))
+	if spread.Min > 0 {
+		spread.Ratio = spread.Max / spread.Min
+	}
+	return spread
+}
+
+// closestRange returns range of consecutive slots whose keys are closest to target, shorter range is preferred on tie
+func closestRange(slotKeys []SlotKeys, target int64) (start int, end int, keys int64) {
+	sorted, prefix, runEnd := slotRuns(slotKeys)
+	best := int64(-1)
+	for i := range sorted {
+		last := runEnd[i]
+		// first end of sum reaching target, and the one before it
+		j := i + sort.Search(last-i+1, func(n int) bool { return prefix[i+n+1]-prefix[i] >= target })
+		for _, e := range []int{j - 1, j} {
+			if e < i || e > last {
+				continue
+			}
+			sum := prefix[e+1] - prefix[i]
+			diff := abs64(sum - target)
+			if best < 0 || diff < best || (diff == best && e-i < end-start) {
+				best, start, end, keys = diff, sorted[i].Slot, sorted[e].Slot, sum
+			}
+		}
+	}
+	return start, end, keys
+}
+
+// floorRange returns range of consecutive slots of most keys not over limit, shorter range is preferred on tie
+//
+// keys is 0 when every slot is over limit.
+func floorRange(slotKeys []SlotKeys, limit int64) (start int, end int, keys int64) {
+	sorted, prefix, runEnd := slotRuns(slotKeys)
+	found := false
+	for i := range sorted {
+		last := runEnd[i]
+		// last end of sum not over limit
+		e := i + sort.Search(last-i+1, func(n int) bool { return prefix[i+n+1]-prefix[i] > limit }) - 1
+		if e < i {
+			continue
+		}
+		sum := prefix[e+1] - prefix[i]
+		if !found || sum > keys || (sum == keys && e-i < end-start) {
+			found, start, end, keys = true, sorted[i].Slot, sorted[e].Slot, sum
+		}
+	}
+	return start, end, keys
+}
+
+// slotRuns returns slotKeys sorted by slot, prefix sums of their keys and last of consecutive slots from each
+func slotRuns(slotKeys []SlotKeys) (sorted []SlotKeys, prefix []int64, runEnd []int) {
+	sorted = append([]SlotKeys(nil), slotKeys...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Slot < sorted[j].Slot })
+
+	// prefix[i] is keys of sorted[:i], keys are not negative so prefix is not decreasing
+	prefix = make([]int64, len(sorted)+1)
+	for i, s := range sorted {
+		prefix[i+1] = prefix[i] + s.Keys
+	}
+	runEnd = make([]int, len(sorted))
+	for i := len(sorted) - 1; i >= 0; i-- {
+		runEnd[i] = i
+		if i+1 < len(sorted) && sorted[i+1].Slot == sorted[i].Slot+1 {
+			runEnd[i] = runEnd[i+1]
+		}
+	}
+	return sorted, prefix, runEnd
+}
+
+func abs64(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
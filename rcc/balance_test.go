@@ -0,0 +1,150 @@
+package rcc
+
+import (
+	"testing"
+)
+
+// testSlotKeys returns keys of every slot from start
+func testSlotKeys(start int, keys ...int64) []SlotKeys {
+	slotKeys := make([]SlotKeys, len(keys))
+	for i, k := range keys {
+		slotKeys[i] = SlotKeys{Slot: start + i, Keys: k}
+	}
+	return slotKeys
+}
+
+func TestClosestRange(t *testing.T) {
+	tests := []struct {
+		name      string
+		slotKeys  []SlotKeys
+		target    int64
+		wantStart int
+		wantEnd   int
+		wantKeys  int64
+	}{
+		{name: "exact range", slotKeys: testSlotKeys(0, 5, 10, 20, 5), target: 30, wantStart: 1, wantEnd: 2, wantKeys: 30},
+		{name: "single slot", slotKeys: testSlotKeys(100, 1, 50, 1), target: 50, wantStart: 101, wantEnd: 101, wantKeys: 50},
+		{name: "shorter range on tie", slotKeys: testSlotKeys(0, 10, 0, 0), target: 10, wantStart: 0, wantEnd: 0, wantKeys: 10},
+		{name: "unsorted slots", slotKeys: []SlotKeys{{Slot: 2, Keys: 7}, {Slot: 0, Keys: 1}, {Slot: 1, Keys: 3}}, target: 10, wantStart: 1, wantEnd: 2, wantKeys: 10},
+		{
+			// slots 10 and 20 are not consecutive, range closest to 40 within runs is 20-21
+			name:     "range does not cross gap of slots",
+			slotKeys: append(testSlotKeys(9, 5, 20), testSlotKeys(20, 20, 15)...),
+			target:   40, wantStart: 20, wantEnd: 21, wantKeys: 35,
+		},
+		{name: "zero keys", slotKeys: testSlotKeys(0, 0, 0), target: 0, wantStart: 0, wantEnd: 0, wantKeys: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, keys := closestRange(tt.slotKeys, tt.target)
+			if start != tt.wantStart || end != tt.wantEnd || keys != tt.wantKeys {
+				t.Errorf("closestRange() = %d-%d (%d keys), want %d-%d (%d keys)", start, end, keys, tt.wantStart, tt.wantEnd, tt.wantKeys)
+			}
+		})
+	}
+}
+
+func TestFloorRange(t *testing.T) {
+	tests := []struct {
+		name      string
+		slotKeys  []SlotKeys
+		limit     int64
+		wantStart int
+		wantEnd   int
+		wantKeys  int64
+	}{
+		{name: "exact range", slotKeys: testSlotKeys(0, 5, 10, 20, 5), limit: 30, wantStart: 1, wantEnd: 2, wantKeys: 30},
+		{name: "range under limit", slotKeys: testSlotKeys(0, 5, 10, 20, 5), limit: 29, wantStart: 2, wantEnd: 3, wantKeys: 25},
+		{name: "every slot over limit", slotKeys: testSlotKeys(0, 60, 60), limit: 50, wantKeys: 0},
+		{
+			name:     "range does not cross gap of slots",
+			slotKeys: append(testSlotKeys(9, 5, 20), testSlotKeys(20, 20, 15)...),
+			limit:    40, wantStart: 20, wantEnd: 21, wantKeys: 35,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, keys := floorRange(tt.slotKeys, tt.limit)
+			if keys != tt.wantKeys || (keys > 0 && (start != tt.wantStart || end != tt.wantEnd)) {
+				t.Errorf("floorRange() = %d-%d (%d keys), want %d-%d (%d keys)", start, end, keys, tt.wantStart, tt.wantEnd, tt.wantKeys)
+			}
+		})
+	}
+}
+
+func TestNewBalance(t *testing.T) {
+	shard := func(id string, slotKeys ...SlotKeys) ShardStats {
+		return ShardStats{ID: id, Addr: id + ":6379", Slots: len(slotKeys), SlotKeys: slotKeys}
+	}
+	tests := []struct {
+		name      string
+		shards    []ShardStats
+		wantRatio float64
+		wantMove  *BalanceMove
+	}{
+		{
+			name:      "balanced cluster",
+			shards:    []ShardStats{shard("a", testSlotKeys(0, 10, 10)...), shard("b", testSlotKeys(2, 10, 10)...)},
+			wantRatio: 1,
+		},
+		{
+			name:      "single master",
+			shards:    []ShardStats{shard("a", testSlotKeys(0, 10, 90)...)},
+			wantRatio: 1,
+		},
+		{
+			name:   "zero keys",
+			shards: []ShardStats{shard("a", testSlotKeys(0, 0, 0)...), shard("b", testSlotKeys(2, 0, 0)...)},
+		},
+		{
+			name:   "master of zero keys",
+			shards: []ShardStats{shard("a", testSlotKeys(0, 30, 10)...), shard("b", testSlotKeys(2, 0, 0)...)},
+			wantMove: &BalanceMove{
+				Slots: "0", Keys: 30, From: NodeRef{ID: "a", Addr: "a:6379"}, To: NodeRef{ID: "b", Addr: "b:6379"}, StdDevAfter: 10,
+			},
+		},
+		{
+			// half of difference is 40, slots 10 and 20 are not consecutive so 20-21 of 35 keys is moved
+			name: "best move does not cross gap of slots",
+			shards: []ShardStats{
+				shard("a", append(append(testSlotKeys(9, 5, 20), testSlotKeys(20, 20, 15)...), testSlotKeys(30, 20)...)...),
+				shard("b", testSlotKeys(40, 0)...),
+			},
+			wantMove: &BalanceMove{
+				Slots: "20-21", Keys: 35, From: NodeRef{ID: "a", Addr: "a:6379"}, To: NodeRef{ID: "b", Addr: "b:6379"}, StdDevAfter: 5,
+			},
+		},
+		{
+			// most loaded masters own a single hot slot each, only b has range to move to least loaded c
+			name: "most loaded master has no range to move",
+			shards: []ShardStats{
+				shard("a", testSlotKeys(0, 60)...),
+				shard("b", testSlotKeys(10, 10, 10, 10, 10)...),
+				shard("c", testSlotKeys(20, 0)...),
+				shard("d", testSlotKeys(30, 60)...),
+			},
+			wantMove: &BalanceMove{
+				Slots: "10-11", Keys: 20, From: NodeRef{ID: "b", Addr: "b:6379"}, To: NodeRef{ID: "c", Addr: "c:6379"}, StdDevAfter: 20,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			balance := NewBalance(tt.shards)
+			if balance.Keys.Ratio != tt.wantRatio {
+				t.Errorf("NewBalance() keys ratio = %v, want %v", balance.Keys.Ratio, tt.wantRatio)
+			}
+			switch {
+			case tt.wantMove == nil && balance.Move != nil:
+				t.Errorf("NewBalance() move = %+v, want none", *balance.Move)
+			case tt.wantMove != nil && balance.Move == nil:
+				t.Errorf("NewBalance() move = none, want %+v", *tt.wantMove)
+			case tt.wantMove != nil && *balance.Move != *tt.wantMove:
+				t.Errorf("NewBalance() move = %+v, want %+v", *balance.Move, *tt.wantMove)
+			}
+		})
+	}
+	if balance := NewBalance(nil); balance.Move != nil || balance.Overloaded != "" {
+		t.Errorf("NewBalance() of no shard = %+v, want zero", balance)
+	}
+}
@@ -0,0 +1,314 @@
+package rcc
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/pkg/errors"
+)
+
+// ClientOptions is connection options shared by every node client
+type ClientOptions struct {
+	User         string
+	Password     string
+	PasswordFile string
+
+	TLS      bool
+	CACert   string
+	Cert     string
+	Key      string
+	SNI      string
+	Insecure bool
+
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	MaxRetries   int // retries of read-only command, mutating command such as MIGRATE is never retried
+
+	tlsConfig *tls.Config
+}
+
+// DefaultClientOptions is default connection options, a node not responding in timeouts fails instead of hanging
+var DefaultClientOptions = ClientOptions{
+	DialTimeout:  5 * time.Second,
+	ReadTimeout:  10 * time.Second,
+	WriteTimeout: 10 * time.Second,
+	MaxRetries:   1,
+}
+
+// SetFlags register connection options into flags
+func (opt *ClientOptions) SetFlags(flags *flag.FlagSet) {
+	flags.StringVar(&opt.User, "user", opt.User, "user")
+	flags.StringVar(&opt.Password, "password", opt.Password, "password")
+	flags.StringVar(&opt.PasswordFile, "password-file", opt.PasswordFile, "password-file")
+	flags.BoolVar(&opt.TLS, "tls", opt.TLS, "tls")
+	flags.StringVar(&opt.CACert, "cacert", opt.CACert, "cacert")
+	flags.StringVar(&opt.Cert, "cert", opt.Cert, "cert")
+	flags.StringVar(&opt.Key, "key", opt.Key, "key")
+	flags.StringVar(&opt.SNI, "sni", opt.SNI, "sni")
+	flags.BoolVar(&opt.Insecure, "insecure", opt.Insecure, "insecure")
+	flags.DurationVar(&opt.DialTimeout, "dial-timeout", opt.DialTimeout, "dial-timeout")
+	flags.DurationVar(&opt.ReadTimeout, "timeout", opt.ReadTimeout, "timeout")
+	flags.IntVar(&opt.MaxRetries, "retries", opt.MaxRetries, "retries")
+}
+
+// Load fill empty options from password file and environment variables, and load TLS certificates
+//
+// Password is taken from --password, --password-file, RCC_AUTH and REDISCLI_AUTH in this order,
+// user is taken from --user, RCC_USER and REDISCLI_USER in this order.
+// Load must be called before NewClient when TLS is enabled.
+func (opt *ClientOptions) Load() (err error) {
+	if opt.User == "" {
+		opt.User = firstEnv("RCC_USER", "REDISCLI_USER")
+	}
+	if opt.Password == "" && opt.PasswordFile != "" {
+		b, err := ioutil.ReadFile(opt.PasswordFile)
+		if err != nil {
+			err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+			return err
+		}
+		opt.Password = strings.TrimRight(string(b), "\r\n")
+	}
+	if opt.Password == "" {
+		opt.Password = firstEnv("RCC_AUTH", "REDISCLI_AUTH")
+	}
+	if opt.TLS {
+		opt.tlsConfig, err = opt.TLSConfig()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TLSConfig returns TLS config built from CA certificate, client certificate and SNI options
+func (opt *ClientOptions) TLSConfig() (config *tls.Config, err error) {
+	config = &tls.Config{
+		ServerName:         opt.SNI,
+		InsecureSkipVerify: opt.Insecure,
+	}
+	if opt.CACert != "" {
+		pem, err := ioutil.ReadFile(opt.CACert)
+		if err != nil {
+			err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			err = errors.New(fmt.Sprintf("No certificate is found in %s", opt.CACert))
+			err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+			return nil, err
+		}
+		config.RootCAs = pool
+	}
+	if opt.Cert != "" || opt.Key != "" {
+		cert, err := tls.LoadX509KeyPair(opt.Cert, opt.Key)
+		if err != nil {
+			err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// NewClient returns client connecting addr with options
+func NewClient(addr string, opt ClientOptions) Client {
+	return &redisClient{client: NewGoRedisClient(addr, opt), user: opt.User, password: opt.Password}
+}
+
+// NewGoRedisClient returns go-redis client connecting addr with options
+func NewGoRedisClient(addr string, opt ClientOptions) *redis.Client {
+	options := &redis.Options{
+		Addr:         addr,
+		DialTimeout:  opt.DialTimeout,
+		ReadTimeout:  opt.ReadTimeout,
+		WriteTimeout: opt.WriteTimeout,
+		// go-redis retries every command, retryReadOnly retries only commands safe to run twice
+		MaxRetries: 0,
+	}
+	if options.WriteTimeout == 0 {
+		options.WriteTimeout = options.ReadTimeout
+	}
+	if opt.tlsConfig != nil {
+		// every node shares CA and client certificate, clone config because ServerName differs per node
+		options.TLSConfig = opt.tlsConfig.Clone()
+		if options.TLSConfig.ServerName == "" {
+			// tls.Client does not fill ServerName from dial address, IP address is verified by IP SAN
+			if host, _, err := net.SplitHostPort(addr); err == nil {
+				options.TLSConfig.ServerName = host
+			}
+		}
+	}
+	if opt.User != "" {
+		// go-redis sends only 'AUTH password', ACL user needs 'AUTH user password'
+		options.OnConnect = authUser(opt.User, opt.Password, nil)
+	} else {
+		options.Password = opt.Password
+	}
+	client := redis.NewClient(options)
+	traceCommands(client, addr)
+	retryReadOnly(client, opt.MaxRetries)
+	return client
+}
+
+// retryBackoff is wait before retry of failed command
+var retryBackoff = 100 * time.Millisecond
+
+// retryReadOnly retries command and pipeline failed by connection error up to retries times unless it is mutating,
+// MIGRATE, SETSLOT or FORGET timed out may have run on node and running it again is not safe
+func retryReadOnly(client *redis.Client, retries int) {
+	if retries <= 0 {
+		return
+	}
+	client.WrapProcess(func(process func(cmd redis.Cmder) error) func(cmd redis.Cmder) error {
+		return func(cmd redis.Cmder) error {
+			err := process(cmd)
+			for attempt := 0; attempt < retries && isRetryable(err) && !IsMutatingCommand(cmd.Args()); attempt++ {
+				time.Sleep(retryBackoff)
+				err = process(cmd)
+			}
+			return err
+		}
+	})
+	client.WrapProcessPipeline(func(process func(cmds []redis.Cmder) error) func(cmds []redis.Cmder) error {
+		return func(cmds []redis.Cmder) error {
+			mutating := false
+			for _, cmd := range cmds {
+				mutating = mutating || IsMutatingCommand(cmd.Args())
+			}
+			err := process(cmds)
+			for attempt := 0; attempt < retries && isRetryable(err) && !mutating; attempt++ {
+				time.Sleep(retryBackoff)
+				err = process(cmds)
+			}
+			return err
+		}
+	})
+}
+
+// isRetryable returns true if err is connection error or node is loading, not error replied to command
+func isRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return true
+	}
+	if _, ok := err.(net.Error); ok {
+		return true
+	}
+	return strings.HasPrefix(err.Error(), "LOADING ") || strings.HasPrefix(err.Error(), "CLUSTERDOWN ")
+}
+
+// traceCommands logs every command and pipeline sent by client at debug level of DefaultLogger,
+// and appends mutating commands to DefaultAudit
+func traceCommands(client *redis.Client, addr string) {
+	client.WrapProcess(func(process func(cmd redis.Cmder) error) func(cmd redis.Cmder) error {
+		return func(cmd redis.Cmder) error {
+			start := time.Now()
+			err := process(cmd)
+			traceCommand(addr, cmd, time.Since(start))
+			return err
+		}
+	})
+	client.WrapProcessPipeline(func(process func(cmds []redis.Cmder) error) func(cmds []redis.Cmder) error {
+		return func(cmds []redis.Cmder) error {
+			start := time.Now()
+			err := process(cmds)
+			elapsed := time.Since(start)
+			for _, cmd := range cmds {
+				traceCommand(addr, cmd, elapsed)
+			}
+			return err
+		}
+	})
+}
+
+func traceCommand(addr string, cmd redis.Cmder, elapsed time.Duration) {
+	err := cmd.Err()
+	if err == redis.Nil {
+		err = nil
+	}
+	if audit := DefaultAudit; audit != nil && IsMutatingCommand(cmd.Args()) {
+		audit.Command(addr, commandArgs(cmd.Args()), err)
+	}
+	if logger := DefaultLogger; logger.Enabled(LevelDebug) {
+		kv := []interface{}{"node", addr, "cmd", commandArgs(cmd.Args()), "elapsed", elapsed}
+		if err != nil {
+			kv = append(kv, "error", err)
+		}
+		logger.Debug("redis command", kv...)
+	}
+}
+
+// Manager holds node clients keyed by node address, every client shares same options
+type Manager struct {
+	dial    func(addr string) Client
+	mu      sync.Mutex
+	clients map[string]Client
+}
+
+// NewManager returns connection manager, options must be loaded
+func NewManager(opt ClientOptions) *Manager {
+	return NewManagerFunc(func(addr string) Client {
+		return NewClient(addr, opt)
+	})
+}
+
+// NewManagerFunc returns connection manager creating client with dial, fake client can be dialed in tests
+func NewManagerFunc(dial func(addr string) Client) *Manager {
+	return &Manager{
+		dial:    dial,
+		clients: make(map[string]Client),
+	}
+}
+
+// Client returns client connecting addr, client is reused for same addr
+func (m *Manager) Client(addr string) Client {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	client, ok := m.clients[addr]
+	if !ok {
+		client = m.dial(addr)
+		m.clients[addr] = client
+	}
+	return client
+}
+
+// NodeClient returns client connecting cluster node
+func (m *Manager) NodeClient(node ClusterNode) Client {
+	return m.Client(node.Addr())
+}
+
+// Close closes every client and returns first error
+func (m *Manager) Close() (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for addr, client := range m.clients {
+		if cerr := client.Close(); cerr != nil && err == nil {
+			err = errors.Wrap(cerr, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+		}
+		delete(m.clients, addr)
+	}
+	return err
+}
+
+func firstEnv(keys ...string) string {
+	for _, key := range keys {
+		if v := os.Getenv(key); v != "" {
+			return v
+		}
+	}
+	return ""
+}
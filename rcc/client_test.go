@@ -0,0 +1,252 @@
+package rcc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testCert is certificate and its key signed by CA, or self-signed CA
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func newTestCert(t *testing.T, template *x509.Certificate, ca *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.SerialNumber = serial
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	parent, signer := template, key
+	if ca != nil {
+		parent, signer = ca.cert, ca.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+func newTestCA(t *testing.T, name string) *testCert {
+	return newTestCert(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: name},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil)
+}
+
+// write writes certificate and key into PEM files of dir, and returns their paths
+func (c *testCert) write(t *testing.T, dir string, name string) (certPath string, keyPath string) {
+	t.Helper()
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPath, keyPath = filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	if err := ioutil.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certPath, keyPath
+}
+
+func (c *testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.der}, PrivateKey: c.key}
+}
+
+// tlsServer starts TLS server of cert answering every command, client certificate signed by clientCA is required unless nil
+func tlsServer(t *testing.T, cert *testCert, clientCA *testCert) string {
+	t.Helper()
+	config := &tls.Config{Certificates: []tls.Certificate{cert.tlsCertificate()}}
+	if clientCA != nil {
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+		config.ClientCAs = x509.NewCertPool()
+		config.ClientCAs.AddCert(clientCA.cert)
+	}
+	l, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve(t, l, 0, "PONG")
+	return l.Addr().String()
+}
+
+func TestTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca, otherCA := newTestCA(t, "rcc test CA"), newTestCA(t, "other CA")
+	caPath, _ := ca.write(t, dir, "ca")
+	otherCAPath, _ := otherCA.write(t, dir, "other-ca")
+
+	// node certificate names localhost and 127.0.0.1, other node certificate names only node.example
+	server := newTestCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "localhost"},
+		DNSNames:    []string{"localhost"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca)
+	named := newTestCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "node.example"},
+		DNSNames:    []string{"node.example"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca)
+	client := newTestCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "rcc"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca)
+	certPath, keyPath := client.write(t, dir, "client")
+
+	addr := tlsServer(t, server, nil)
+	_, port, _ := net.SplitHostPort(addr)
+	namedAddr := tlsServer(t, named, nil)
+	mtlsAddr := tlsServer(t, server, ca)
+
+	tests := []struct {
+		name    string
+		addr    string
+		opt     ClientOptions
+		wantErr string
+	}{
+		{name: "IP is verified by IP SAN", addr: addr, opt: ClientOptions{CACert: caPath}},
+		{name: "hostname is verified by DNS SAN", addr: net.JoinHostPort("localhost", port), opt: ClientOptions{CACert: caPath}},
+		{name: "unknown CA", addr: addr, opt: ClientOptions{CACert: otherCAPath}, wantErr: "certificate signed by unknown authority"},
+		{name: "name mismatch", addr: namedAddr, opt: ClientOptions{CACert: caPath}, wantErr: "127.0.0.1"},
+		{name: "SNI overrides node address", addr: namedAddr, opt: ClientOptions{CACert: caPath, SNI: "node.example"}},
+		{name: "insecure skips verification", addr: namedAddr, opt: ClientOptions{CACert: otherCAPath, Insecure: true}},
+		{name: "client certificate", addr: mtlsAddr, opt: ClientOptions{CACert: caPath, Cert: certPath, Key: keyPath}},
+		{name: "client certificate is required", addr: mtlsAddr, opt: ClientOptions{CACert: caPath}, wantErr: "certificate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opt := tt.opt
+			opt.TLS = true
+			opt.DialTimeout, opt.ReadTimeout = 5*time.Second, 5*time.Second
+			if err := opt.Load(); err != nil {
+				t.Fatal(err)
+			}
+			client := NewGoRedisClient(tt.addr, opt)
+			defer client.Close()
+			err := client.Ping().Err()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("PING error = %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("PING error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTLSServerNamePerNode(t *testing.T) {
+	opt := ClientOptions{TLS: true}
+	if err := opt.Load(); err != nil {
+		t.Fatal(err)
+	}
+	for addr, want := range map[string]string{
+		"node1.example:6379": "node1.example",
+		"10.0.0.2:6379":      "10.0.0.2",
+		"[::1]:6379":         "::1",
+	} {
+		client := NewGoRedisClient(addr, opt)
+		if got := client.Options().TLSConfig.ServerName; got != want {
+			t.Errorf("ServerName of %s = %q, want %q", addr, got, want)
+		}
+		client.Close()
+	}
+	if opt.tlsConfig.ServerName != "" {
+		t.Errorf("shared TLS config is modified into ServerName %q", opt.tlsConfig.ServerName)
+	}
+
+	opt = ClientOptions{TLS: true, SNI: "cluster.example"}
+	if err := opt.Load(); err != nil {
+		t.Fatal(err)
+	}
+	client := NewGoRedisClient("10.0.0.2:6379", opt)
+	defer client.Close()
+	if got := client.Options().TLSConfig.ServerName; got != "cluster.example" {
+		t.Errorf("ServerName with SNI = %q, want %q", got, "cluster.example")
+	}
+}
+
+func TestTLSConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	notPEM := filepath.Join(dir, "ca.crt")
+	if err := ioutil.WriteFile(notPEM, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, opt := range []ClientOptions{
+		{CACert: filepath.Join(dir, "missing.crt")},
+		{CACert: notPEM},
+		{Cert: filepath.Join(dir, "missing.crt"), Key: filepath.Join(dir, "missing.key")},
+	} {
+		if _, err := opt.TLSConfig(); err == nil {
+			t.Errorf("TLSConfig() of %+v error = nil", opt)
+		}
+	}
+}
+
+func TestRetryReadOnly(t *testing.T) {
+	// first command of every server is dropped by closing connection
+	flaky := func(t *testing.T) (addr string, commands *int32) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		commands = new(int32)
+		serveFunc(t, l, func() (string, bool) {
+			return "OK", atomic.AddInt32(commands, 1) > 1
+		})
+		return l.Addr().String(), commands
+	}
+	opt := DefaultClientOptions
+	opt.MaxRetries = 1
+
+	addr, commands := flaky(t)
+	client := NewClient(addr, opt)
+	defer client.Close()
+	if _, err := client.ClusterNodes(context.Background()); err != nil {
+		t.Errorf("CLUSTER NODES error = %v, want retried", err)
+	}
+	if n := atomic.LoadInt32(commands); n != 2 {
+		t.Errorf("CLUSTER NODES is sent %d times, want 2", n)
+	}
+
+	addr, commands = flaky(t)
+	client = NewClient(addr, opt)
+	defer client.Close()
+	if err := client.ClusterMeet(context.Background(), "127.0.0.1", "7000"); err == nil {
+		t.Error("CLUSTER MEET error = nil, want connection error")
+	}
+	if n := atomic.LoadInt32(commands); n != 1 {
+		t.Errorf("CLUSTER MEET is sent %d times, want 1", n)
+	}
+}
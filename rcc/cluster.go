@@ -1,13 +1,15 @@
 package rcc
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
-	"github.com/go-redis/redis"
 	"github.com/pkg/errors"
 )
 
@@ -15,7 +17,8 @@ import (
 type Slot struct {
 	Start uint64
 	End   uint64
-	From  string
+	From  string // importing from node ID
+	To    string // migrating to node ID
 }
 
 // ClusterNode is redis cluster node struct
@@ -36,19 +39,35 @@ type ClusterNode struct {
 	Slots       []Slot
 }
 
-// ClusterNodes provide 'CLUSTER NODES' command result
-func ClusterNodes(client *redis.Client) (cluster []ClusterNode, err error) {
+// Addr returns node address to connect
+func (node ClusterNode) Addr() string {
+	return net.JoinHostPort(node.IP, strconv.FormatUint(node.Port, 10))
+}
 
-	// err := client.Set("key", "value", 0).Err()
-	nodes := client.ClusterNodes()
+// ClusterNodes provide 'CLUSTER NODES' command result
+func ClusterNodes(client Client) (cluster []ClusterNode, err error) {
+	return ClusterNodesContext(context.Background(), client)
+}
 
-	val, err := nodes.Result()
+// ClusterNodesContext is ClusterNodes bounded by ctx, ctx is passed down to command and reverse lookups
+func ClusterNodesContext(ctx context.Context, client Client) (cluster []ClusterNode, err error) {
+	val, err := client.ClusterNodes(ctx)
 	if err != nil {
 		err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
 		return nil, err
 	}
-	val = strings.TrimSpace(val)
 
+	cluster, err = ParseClusterNodes(val)
+	if err != nil {
+		return nil, err
+	}
+	// Cluster Node host
+	ResolveHosts(ctx, DefaultResolver, cluster)
+	return cluster, nil
+}
+
+// ParseClusterNodes parse 'CLUSTER NODES' output or nodes.conf content, host is not resolved
+func ParseClusterNodes(val string) (cluster []ClusterNode, err error) {
 	re, err := regexp.Compile(":(\\d+)$")
 	if err != nil {
 		err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
@@ -56,35 +75,40 @@ func ClusterNodes(client *redis.Client) (cluster []ClusterNode, err error) {
 	}
 
 	for _, line := range strings.Split(val, "\n") {
+		line = strings.TrimSpace(line)
+		// nodes.conf has trailing 'vars currentEpoch 6 lastVoteEpoch 0' line
+		if line == "" || strings.HasPrefix(line, "vars ") {
+			continue
+		}
+
 		var node ClusterNode
-		rows := strings.Split(line, " ")
+		rows := strings.Fields(line)
+		if len(rows) < 8 {
+			err = errors.Wrap(&ParseError{Line: line, Err: ErrMalformedLine}, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+			return nil, err
+		}
 		// Cluster Node ID
 		node.ID = rows[0]
 
-		// Cluster Node IP address
+		// Cluster Node IP address, redis 4.0 or later appends '@cport' and redis 7.0 appends ',hostname'
+		addr := strings.SplitN(rows[1], "@", 2)[0]
+		addr = strings.SplitN(addr, ",", 2)[0]
 		var port uint64
-		submatch := re.FindStringSubmatch(rows[1])
+		submatch := re.FindStringSubmatch(addr)
 		if len(submatch) == 2 {
 			port, err = strconv.ParseUint(submatch[1], 10, 64)
 			if err != nil {
-				err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+				err = errors.Wrap(&ParseError{Line: line, Err: err}, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
 				return nil, err
 			}
 		} else {
-			err = errors.New("Port is not found")
-			err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+			err = errors.Wrap(&ParseError{Line: line, Err: ErrPortNotFound}, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
 			return nil, err
 		}
 
-		ip := strings.TrimSuffix(rows[1], fmt.Sprint(":", port))
+		ip := strings.TrimSuffix(addr, fmt.Sprint(":", port))
 		node.IP = ip
-		// Cluster Node host
-		hosts, err := net.LookupAddr(node.IP)
-		if err != nil || len(hosts) == 0 {
-			node.Host = node.IP
-		} else {
-			node.Host = hosts[0]
-		}
+		node.Host = ip
 		// Cluster Node port number
 		node.Port = port
 
@@ -107,21 +131,21 @@ func ClusterNodes(client *redis.Client) (cluster []ClusterNode, err error) {
 		// Cluster Node ping sent
 		node.PingSent, err = strconv.ParseUint(rows[4], 10, 64)
 		if err != nil {
-			err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+			err = errors.Wrap(&ParseError{Line: line, Err: err}, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
 			return nil, err
 		}
 
 		// Cluster Node pong recv
 		node.PongRecv, err = strconv.ParseUint(rows[5], 10, 64)
 		if err != nil {
-			err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+			err = errors.Wrap(&ParseError{Line: line, Err: err}, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
 			return nil, err
 		}
 
 		// Cluster Node config epoch
 		node.ConfigEpoch, err = strconv.ParseUint(rows[6], 10, 64)
 		if err != nil {
-			err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+			err = errors.Wrap(&ParseError{Line: line, Err: err}, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
 			return nil, err
 		}
 
@@ -136,25 +160,39 @@ func ClusterNodes(client *redis.Client) (cluster []ClusterNode, err error) {
 				if strings.HasPrefix(sRange, "[") {
 					sRange = strings.TrimLeft(sRange, "[")
 					sRange = strings.TrimRight(sRange, "]")
-					s := strings.Split(sRange, "-<-")
+					// importing slot is '[slot-<-from]' and migrating slot is '[slot->-to]'
+					s := strings.SplitN(sRange, "-", 3)
+					if len(s) != 3 {
+						err = errors.Wrap(&ParseError{Line: line, Err: ErrMalformedSlot}, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+						return nil, err
+					}
 					slot.Start, err = strconv.ParseUint(s[0], 10, 64)
 					if err != nil {
-						err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+						err = errors.Wrap(&ParseError{Line: line, Err: err}, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
 						return nil, err
 					}
 					slot.End = 0
-					slot.From = s[2]
+					switch s[1] {
+					case "<":
+						slot.From = s[2]
+					case ">":
+						slot.To = s[2]
+					}
 				} else {
 					s := strings.Split(sRange, "-")
 					slot.Start, err = strconv.ParseUint(s[0], 10, 64)
 					if err != nil {
-						err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+						err = errors.Wrap(&ParseError{Line: line, Err: err}, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
 						return nil, err
 					}
-					slot.End, err = strconv.ParseUint(s[1], 10, 64)
-					if err != nil {
-						err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
-						return nil, err
+					// single slot is printed without range
+					slot.End = slot.Start
+					if len(s) > 1 {
+						slot.End, err = strconv.ParseUint(s[1], 10, 64)
+						if err != nil {
+							err = errors.Wrap(&ParseError{Line: line, Err: err}, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+							return nil, err
+						}
 					}
 					slot.From = ""
 				}
@@ -171,49 +209,169 @@ func ClusterNodes(client *redis.Client) (cluster []ClusterNode, err error) {
 	return cluster, nil
 }
 
+// NodesConf is parsed nodes.conf or saved 'CLUSTER NODES' output
+type NodesConf struct {
+	Nodes         []ClusterNode
+	CurrentEpoch  uint64
+	LastVoteEpoch uint64
+}
+
+// ReadNodesConf read nodes.conf or saved 'CLUSTER NODES' output from file
+func ReadNodesConf(path string) (conf NodesConf, err error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+		return conf, err
+	}
+	val := string(b)
+
+	conf.Nodes, err = ParseClusterNodes(val)
+	if err != nil {
+		return conf, err
+	}
+	for _, line := range strings.Split(val, "\n") {
+		rows := strings.Fields(line)
+		if len(rows) == 0 || rows[0] != "vars" {
+			continue
+		}
+		for i := 1; i+1 < len(rows); i += 2 {
+			var v *uint64
+			switch rows[i] {
+			case "currentEpoch":
+				v = &conf.CurrentEpoch
+			case "lastVoteEpoch":
+				v = &conf.LastVoteEpoch
+			default:
+				continue
+			}
+			*v, err = strconv.ParseUint(rows[i+1], 10, 64)
+			if err != nil {
+				err = errors.Wrap(&ParseError{Line: strings.TrimSpace(line), Err: err}, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+				return conf, err
+			}
+		}
+	}
+	return conf, nil
+}
+
 // DescribeIP return IP, when string is hostname it resolve hostname, or ip return ip, nor returns nil
+//
+// First address is returned when hostname has several addresses, use ResolveNode to tell nodes apart.
 func DescribeIP(s string) (ip net.IP, err error) {
+	return DescribeIPContext(context.Background(), s)
+}
+
+// DescribeIPContext is DescribeIP bounded by ctx
+func DescribeIPContext(ctx context.Context, s string) (ip net.IP, err error) {
 	ip = net.ParseIP(s)
 	if ip != nil {
-		addrs, err := net.LookupAddr(s)
-		if err != nil {
-			err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
-			return nil, err
-		}
-		ip = net.ParseIP(addrs[0])
 		return ip, nil
 	}
-	return ip, nil
+	addrs, err := DefaultHostResolver.LookupIPAddr(ctx, s)
+	if err != nil {
+		err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, nil
+	}
+	return addrs[0].IP, nil
+}
+
+// nodeID returns ID of node connected by client
+func nodeID(ctx context.Context, client Client) (id string, err error) {
+	val, err := client.ClusterNodes(ctx)
+	if err != nil {
+		err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+		return "", err
+	}
+	nodes, err := ParseClusterNodes(val)
+	if err != nil {
+		return "", err
+	}
+	for _, node := range nodes {
+		if node.HasFlag("myself") {
+			return node.ID, nil
+		}
+	}
+	err = errors.Wrap(ErrMyselfNotFound, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+	return "", err
 }
 
 // AssertEmptyNode check node empty and returns nil if node is empty
-func AssertEmptyNode(client *redis.Client) (err error) {
-	resp, err := client.ClusterInfo().Result()
+func AssertEmptyNode(client Client) (err error) {
+	return AssertEmptyNodeContext(context.Background(), client)
+}
+
+// AssertEmptyNodeContext is AssertEmptyNode bounded by ctx, *NodeNotEmptyError is returned if node is not empty
+//
+// Node knowing other nodes is not empty as well as node having keys, *ParseError is returned when
+// 'CLUSTER INFO' has no cluster_known_nodes.
+func AssertEmptyNodeContext(ctx context.Context, client Client) (err error) {
+	resp, err := client.ClusterInfo(ctx)
 	if err != nil {
 		err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
 		return err
 	}
-	resp = strings.TrimSpace(resp)
-	for _, line := range strings.Split(resp, "\n") {
-		row := strings.Split(line, ":")
-		if row[0] == "cluster_known_nodes" {
-			value, err := strconv.ParseUint(strings.TrimSpace(row[1]), 10, 64)
-			if err != nil {
-				err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
-				return err
-			}
-			if value == 1 {
-				resp, err = client.Info("db0").Result()
-				if err != nil {
-					err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
-					return err
-				}
-				if resp != "" {
-					return errors.New("node is not empty, either the node already knows other nodes (check with CLUSTER NODES) or contains some key in database 0")
+	value, ok := ParseInfo(resp)["cluster_known_nodes"]
+	if !ok {
+		err = errors.Wrap(&ParseError{Line: "cluster_known_nodes", Err: ErrFieldNotFound}, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+		return err
+	}
+	knownNodes, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		err = errors.Wrap(&ParseError{Line: "cluster_known_nodes:" + value, Err: err}, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+		return err
+	}
+
+	resp, err = client.Info(ctx, "keyspace")
+	if err != nil {
+		err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+		return err
+	}
+	keyspace, err := ParseKeyspace(ParseInfo(resp)["db0"])
+	if err != nil {
+		err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+		return err
+	}
+
+	if knownNodes != 1 || keyspace.Keys != 0 {
+		err = &NodeNotEmptyError{KnownNodes: knownNodes, Keys: keyspace.Keys}
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// WaitJoin waits until node connected by client knows node of id after handshake, or timeout
+func WaitJoin(ctx context.Context, client Client, id string, timeout time.Duration) error {
+	parent := ctx
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		// raw output is polled, hosts of every node are not resolved on each poll
+		var cluster []ClusterNode
+		val, err := client.ClusterNodes(ctx)
+		if err == nil {
+			cluster, err = ParseClusterNodes(val)
+		}
+		if err == nil {
+			for _, node := range cluster {
+				if node.ID == id && !node.HasFlag("handshake") && !node.HasFlag("noaddr") {
+					return nil
 				}
 			}
-			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			if err := parent.Err(); err != nil {
+				return err
+			}
+			err = errors.New(fmt.Sprintf("Node %s is not joined in %v", id, timeout))
+			err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+			return err
 		}
 	}
-	return nil
 }
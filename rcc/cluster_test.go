@@ -0,0 +1,252 @@
+package rcc
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	idA = "07c37dfeb235213a872192d90877d0cd55635b91"
+	idB = "67ed2db8d677e59ec4a4cefb06858cf2a1a89fa1"
+	idC = "e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca"
+)
+
+func TestParseClusterNodes(t *testing.T) {
+	tests := []struct {
+		name    string
+		val     string
+		want    []ClusterNode
+		wantErr error
+	}{
+		{
+			name: "address without cport",
+			val:  idA + " 127.0.0.1:30001 myself,master - 0 0 1 connected 0-5460\n",
+			want: []ClusterNode{{
+				ID: idA, IP: "127.0.0.1", Host: "127.0.0.1", Port: 30001, Flags: []string{"myself", "master"}, Master: true,
+				SlaveOf: "-", ConfigEpoch: 1, LinkState: "connected", Slots: []Slot{{Start: 0, End: 5460}},
+			}},
+		},
+		{
+			name: "address with cport and hostname",
+			val: idA + " 127.0.0.1:30001@40001,node1.example master - 0 1426238317239 1 connected 0-100 200\n" +
+				idB + " 127.0.0.2:30002@40002 slave " + idA + " 1426238316232 1426238317741 1 disconnected\n",
+			want: []ClusterNode{
+				{
+					ID: idA, IP: "127.0.0.1", Host: "127.0.0.1", Port: 30001, Flags: []string{"master"}, Master: true,
+					SlaveOf: "-", PongRecv: 1426238317239, ConfigEpoch: 1, LinkState: "connected",
+					Slots: []Slot{{Start: 0, End: 100}, {Start: 200, End: 200}},
+				},
+				{
+					ID: idB, IP: "127.0.0.2", Host: "127.0.0.2", Port: 30002, Flags: []string{"slave"}, Slave: true,
+					SlaveOf: idA, PingSent: 1426238316232, PongRecv: 1426238317741, ConfigEpoch: 1, LinkState: "disconnected",
+				},
+			},
+		},
+		{
+			name: "open slots",
+			val:  idA + " 127.0.0.1:30001@40001 myself,master - 0 0 1 connected 0-10 [11->-" + idB + "] [12-<-" + idC + "]\n",
+			want: []ClusterNode{{
+				ID: idA, IP: "127.0.0.1", Host: "127.0.0.1", Port: 30001, Flags: []string{"myself", "master"}, Master: true,
+				SlaveOf: "-", ConfigEpoch: 1, LinkState: "connected",
+				Slots: []Slot{{Start: 0, End: 10}, {Start: 11, To: idB}, {Start: 12, From: idC}},
+			}},
+		},
+		{
+			name: "vars line of nodes.conf",
+			val:  idA + " :30001@40001 myself,master - 0 0 0 connected\nvars currentEpoch 6 lastVoteEpoch 0\n",
+			want: []ClusterNode{{
+				ID: idA, Port: 30001, Flags: []string{"myself", "master"}, Master: true, SlaveOf: "-", LinkState: "connected",
+			}},
+		},
+		{name: "too few fields", val: idA + " 127.0.0.1:30001 master - 0 0 1\n", wantErr: ErrMalformedLine},
+		{name: "no port", val: idA + " 127.0.0.1@40001 master - 0 0 1 connected\n", wantErr: ErrPortNotFound},
+		{name: "malformed open slot", val: idA + " 127.0.0.1:30001 master - 0 0 1 connected [11->]\n", wantErr: ErrMalformedSlot},
+		{name: "malformed slot range", val: idA + " 127.0.0.1:30001 master - 0 0 1 connected 0-x\n", wantErr: strconv.ErrSyntax},
+		{name: "malformed epoch", val: idA + " 127.0.0.1:30001 master - 0 0 x connected\n", wantErr: strconv.ErrSyntax},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseClusterNodes(tt.val)
+			if tt.wantErr != nil {
+				var parseErr *ParseError
+				if !errors.As(err, &parseErr) || !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ParseClusterNodes() error = %v, want ParseError of %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseClusterNodes() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseClusterNodes() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadNodesConf(t *testing.T) {
+	tests := []struct {
+		name          string
+		val           string
+		nodes         int
+		currentEpoch  uint64
+		lastVoteEpoch uint64
+		wantErr       bool
+	}{
+		{
+			name: "nodes.conf",
+			val: idA + " 127.0.0.1:30001@40001 myself,master - 0 0 6 connected 0-16383\n" +
+				idB + " 127.0.0.1:30002@40002 slave " + idA + " 0 1426238317741 6 connected\n" +
+				"vars currentEpoch 6 lastVoteEpoch 4\n",
+			nodes: 2, currentEpoch: 6, lastVoteEpoch: 4,
+		},
+		{
+			name:  "saved CLUSTER NODES output",
+			val:   idA + " 127.0.0.1:30001@40001 myself,master - 0 0 6 connected 0-16383\n",
+			nodes: 1,
+		},
+		{
+			name:    "malformed vars",
+			val:     idA + " 127.0.0.1:30001@40001 myself,master - 0 0 6 connected\nvars currentEpoch x\n",
+			wantErr: true,
+		},
+		{
+			name:    "malformed node line",
+			val:     idA + " 127.0.0.1:30001@40001 myself,master\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "nodes.conf")
+			if err := ioutil.WriteFile(path, []byte(tt.val), 0600); err != nil {
+				t.Fatal(err)
+			}
+			conf, err := ReadNodesConf(path)
+			if tt.wantErr {
+				var parseErr *ParseError
+				if !errors.As(err, &parseErr) {
+					t.Fatalf("ReadNodesConf() error = %v, want ParseError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadNodesConf() error = %v", err)
+			}
+			if len(conf.Nodes) != tt.nodes || conf.CurrentEpoch != tt.currentEpoch || conf.LastVoteEpoch != tt.lastVoteEpoch {
+				t.Errorf("ReadNodesConf() = %d nodes, currentEpoch %d, lastVoteEpoch %d, want %d, %d, %d",
+					len(conf.Nodes), conf.CurrentEpoch, conf.LastVoteEpoch, tt.nodes, tt.currentEpoch, tt.lastVoteEpoch)
+			}
+		})
+	}
+	if _, err := ReadNodesConf(filepath.Join(t.TempDir(), "missing.conf")); err == nil {
+		t.Error("ReadNodesConf() of missing file error = nil")
+	}
+}
+
+// infoClient is Client answering 'CLUSTER INFO' and 'INFO keyspace' from scripted replies
+type infoClient struct {
+	Client
+	clusterInfo string
+	keyspace    string
+}
+
+func (c *infoClient) ClusterInfo(ctx context.Context) (string, error) {
+	return c.clusterInfo, nil
+}
+
+func (c *infoClient) Info(ctx context.Context, section string) (string, error) {
+	return c.keyspace, nil
+}
+
+func TestNodeNotEmptyError(t *testing.T) {
+	tests := []struct {
+		name        string
+		clusterInfo string
+		keyspace    string
+		want        *NodeNotEmptyError
+		wantErr     error
+	}{
+		{name: "empty node", clusterInfo: "cluster_state:fail\r\ncluster_known_nodes:1\r\n", keyspace: "# Keyspace\r\n"},
+		{
+			name: "node knows other nodes", clusterInfo: "cluster_known_nodes:3\r\n", keyspace: "# Keyspace\r\n",
+			want: &NodeNotEmptyError{KnownNodes: 3},
+		},
+		{
+			name: "node has keys", clusterInfo: "cluster_known_nodes:1\r\n", keyspace: "db0:keys=5,expires=0,avg_ttl=0\r\n",
+			want: &NodeNotEmptyError{KnownNodes: 1, Keys: 5},
+		},
+		{name: "no known nodes", clusterInfo: "cluster_state:fail\r\n", wantErr: ErrFieldNotFound},
+		{name: "malformed known nodes", clusterInfo: "cluster_known_nodes:x\r\n", wantErr: strconv.ErrSyntax},
+		{name: "malformed keyspace", clusterInfo: "cluster_known_nodes:1\r\n", keyspace: "db0:keys=x\r\n", wantErr: strconv.ErrSyntax},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := AssertEmptyNodeContext(context.Background(), &infoClient{clusterInfo: tt.clusterInfo, keyspace: tt.keyspace})
+			var notEmpty *NodeNotEmptyError
+			var parseErr *ParseError
+			switch {
+			case tt.wantErr != nil:
+				if !errors.As(err, &parseErr) || !errors.Is(err, tt.wantErr) {
+					t.Errorf("AssertEmptyNode() error = %v, want ParseError of %v", err, tt.wantErr)
+				}
+			case tt.want != nil:
+				if !errors.As(err, &notEmpty) || *notEmpty != *tt.want {
+					t.Errorf("AssertEmptyNode() error = %v, want %v", err, tt.want)
+				}
+			case err != nil:
+				t.Errorf("AssertEmptyNode() error = %v", err)
+			}
+		})
+	}
+}
+
+// joinClient is Client answering 'CLUSTER NODES' in which node of id joins on polls-th poll
+type joinClient struct {
+	Client
+	id    string
+	polls int
+}
+
+func (c *joinClient) ClusterNodes(ctx context.Context) (string, error) {
+	c.polls--
+	nodes := idA + " 10.0.0.1:7000@17000 myself,master - 0 0 1 connected 0-16383\n"
+	if c.polls <= 0 {
+		nodes += c.id + " 10.0.0.2:7000@17000 master - 0 0 0 connected\n"
+	}
+	return nodes, nil
+}
+
+// countResolver counts reverse lookups
+type countResolver struct {
+	lookups int32
+}
+
+func (r *countResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	atomic.AddInt32(&r.lookups, 1)
+	return nil, nil
+}
+
+func TestWaitJoin(t *testing.T) {
+	resolver := &countResolver{}
+	defer func(r Resolver) { DefaultResolver = r }(DefaultResolver)
+	DefaultResolver = resolver
+
+	if err := WaitJoin(context.Background(), &joinClient{id: idB, polls: 3}, idB, 5*time.Second); err != nil {
+		t.Errorf("WaitJoin() error = %v", err)
+	}
+	if n := atomic.LoadInt32(&resolver.lookups); n != 0 {
+		t.Errorf("WaitJoin() looks up hosts %d times, want none", n)
+	}
+	if err := WaitJoin(context.Background(), &joinClient{id: idB, polls: 100}, idB, 300*time.Millisecond); err == nil {
+		t.Error("WaitJoin() of node not joining error = nil")
+	}
+}
@@ -0,0 +1,140 @@
+package rcc
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// Config is rcc config file, it defines named clusters and audit log of mutating commands
+//
+//	audit-log: /var/log/rcc/audit.log
+//	clusters:
+//	  prod-cache:
+//	    seeds: ["10.0.0.1:6379", "10.0.0.2:6379"]
+//	    user: admin
+//	    password-file: /etc/rcc/prod-cache.pass
+//	    tls:
+//	      enabled: true
+//	      cacert: /etc/rcc/ca.pem
+//	    hosts:
+//	      10.0.0.1: {host: cache01, zone: ap-northeast-1a}
+type Config struct {
+	AuditLog string                    `yaml:"audit-log"`
+	Clusters map[string]ClusterProfile `yaml:"clusters"`
+}
+
+// ClusterProfile is named cluster in config file
+type ClusterProfile struct {
+	Seeds        []string              `yaml:"seeds"`
+	User         string                `yaml:"user"`
+	Password     string                `yaml:"password"`
+	PasswordFile string                `yaml:"password-file"`
+	TLS          TLSProfile            `yaml:"tls"`
+	Hosts        map[string]HostLabels `yaml:"hosts"`
+}
+
+// TLSProfile is TLS settings of named cluster
+type TLSProfile struct {
+	Enabled  bool   `yaml:"enabled"`
+	CACert   string `yaml:"cacert"`
+	Cert     string `yaml:"cert"`
+	Key      string `yaml:"key"`
+	SNI      string `yaml:"sni"`
+	Insecure bool   `yaml:"insecure"`
+}
+
+// HostLabels is labels of node host, hosts are keyed by IP address, hostname or HOST:PORT
+type HostLabels struct {
+	Host   string            `yaml:"host"`
+	Zone   string            `yaml:"zone"`
+	Labels map[string]string `yaml:"labels"`
+}
+
+// DefaultConfigPath returns $RCC_CONFIG, or config.yaml in $XDG_CONFIG_HOME/rcc or ~/.config/rcc
+func DefaultConfigPath() string {
+	if path := os.Getenv("RCC_CONFIG"); path != "" {
+		return path
+	}
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "rcc", "config.yaml")
+}
+
+// LoadConfig read config file
+func LoadConfig(path string) (config Config, err error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+		return config, err
+	}
+	if err := yaml.UnmarshalStrict(b, &config); err != nil {
+		err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: %s", App.Name, App.Version, path))
+		return config, err
+	}
+	return config, nil
+}
+
+// Cluster returns named cluster profile
+func (config Config) Cluster(name string) (profile ClusterProfile, err error) {
+	profile, ok := config.Clusters[name]
+	if !ok {
+		err = errors.Wrap(ErrUnknownCluster, fmt.Sprintf("%v-%v failed: %s", App.Name, App.Version, name))
+		return profile, err
+	}
+	if len(profile.Seeds) == 0 {
+		err = errors.New(fmt.Sprintf("Cluster '%s' has no seeds", name))
+		err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+		return profile, err
+	}
+	return profile, nil
+}
+
+// Apply fill options not given on command line with profile settings
+func (profile ClusterProfile) Apply(opt *ClientOptions) {
+	if opt.User == "" {
+		opt.User = profile.User
+	}
+	if opt.Password == "" && opt.PasswordFile == "" {
+		opt.Password = profile.Password
+		opt.PasswordFile = profile.PasswordFile
+	}
+	if profile.TLS.Enabled {
+		opt.TLS = true
+	}
+	if opt.CACert == "" {
+		opt.CACert = profile.TLS.CACert
+	}
+	if opt.Cert == "" && opt.Key == "" {
+		opt.Cert = profile.TLS.Cert
+		opt.Key = profile.TLS.Key
+	}
+	if opt.SNI == "" {
+		opt.SNI = profile.TLS.SNI
+	}
+	if profile.TLS.Insecure {
+		opt.Insecure = true
+	}
+}
+
+// Labels returns labels of node host, HOST:PORT is looked up before IP address and hostname
+func (profile ClusterProfile) Labels(node ClusterNode) (labels HostLabels, ok bool) {
+	port := strconv.FormatUint(node.Port, 10)
+	for _, key := range []string{node.Addr(), node.Host + ":" + port, node.IP, node.Host} {
+		if labels, ok = profile.Hosts[key]; ok {
+			return labels, true
+		}
+	}
+	return labels, false
+}
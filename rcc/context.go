@@ -0,0 +1,36 @@
+package rcc
+
+import (
+	"context"
+)
+
+// result is value and error returned by fn of wait
+type result struct {
+	val interface{}
+	err error
+}
+
+// wait runs fn and returns its value, or error of ctx when ctx is done before fn returns
+//
+// go-redis does not watch context, fn is left running until it returns or times out by client options.
+// Value of fn left running is dropped, so that fn must not write variables of its caller.
+// Command canceled by ctx such as MEET, SETSLOT or MIGRATE may still run on the node after wait returns.
+func wait(ctx context.Context, fn func() (interface{}, error)) (interface{}, error) {
+	if ctx.Done() == nil {
+		return fn()
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	resc := make(chan result, 1)
+	go func() {
+		val, err := fn()
+		resc <- result{val: val, err: err}
+	}()
+	select {
+	case res := <-resc:
+		return res.val, res.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
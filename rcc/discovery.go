@@ -0,0 +1,228 @@
+package rcc
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Discovery is cluster topology discovered from seeds
+type Discovery struct {
+	Seed   string           // seed answered 'CLUSTER NODES'
+	Nodes  []ClusterNode    // topology seen from Seed
+	Errors map[string]error // errors of seeds tried before Seed
+}
+
+// SplitSeeds split comma separated node addresses
+func SplitSeeds(args ...string) (seeds []string) {
+	for _, arg := range args {
+		for _, seed := range strings.Split(arg, ",") {
+			if seed = strings.TrimSpace(seed); seed != "" {
+				seeds = append(seeds, seed)
+			}
+		}
+	}
+	return seeds
+}
+
+// Discover returns topology from first reachable seed, seeds are tried in order
+func Discover(manager *Manager, seeds []string) (discovery Discovery, err error) {
+	return DiscoverContext(context.Background(), manager, seeds)
+}
+
+// DiscoverContext is Discover bounded by ctx, seeds are not tried any more after ctx is done
+func DiscoverContext(ctx context.Context, manager *Manager, seeds []string) (discovery Discovery, err error) {
+	discovery.Errors = make(map[string]error)
+	var failed []string
+	fail := func(seed string, err error) {
+		if _, ok := discovery.Errors[seed]; !ok {
+			failed = append(failed, seed)
+		}
+		discovery.Errors[seed] = err
+	}
+
+	var addrs []string
+	for _, seed := range seeds {
+		// any node answers, hostname mapping to several nodes is expanded into every node
+		resolved, err := ResolveAddrContext(ctx, seed)
+		if err != nil {
+			fail(seed, err)
+			continue
+		}
+		addrs = append(addrs, resolved...)
+	}
+	for _, seed := range addrs {
+		if err := ctx.Err(); err != nil {
+			return discovery, err
+		}
+		nodes, err := ClusterNodesContext(ctx, manager.Client(seed))
+		if err != nil {
+			fail(seed, err)
+			continue
+		}
+		discovery.Seed = seed
+		discovery.Nodes = nodes
+		return discovery, nil
+	}
+
+	err = &UnreachableSeedsError{Seeds: failed, Errors: discovery.Errors}
+	err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+	return discovery, err
+}
+
+// CrossCheck compare discovered topology with topology seen from another seed and returns differences
+//
+// Another seed is taken from seeds, or from discovered masters when seeds has no other reachable node. Node
+// answered discovery is told by node ID, so that it is skipped under any hostname or address.
+func CrossCheck(manager *Manager, discovery Discovery, seeds []string) (seed string, diffs []string, err error) {
+	return CrossCheckContext(context.Background(), manager, discovery, seeds)
+}
+
+// CrossCheckContext is CrossCheck bounded by ctx
+func CrossCheckContext(ctx context.Context, manager *Manager, discovery Discovery, seeds []string) (seed string, diffs []string, err error) {
+	var myself string
+	for _, node := range discovery.Nodes {
+		if node.HasFlag("myself") {
+			myself = node.ID
+		}
+	}
+
+	errs := make(map[string]error)
+	var failed []string
+	fail := func(seed string, err error) {
+		if _, ok := errs[seed]; !ok {
+			failed = append(failed, seed)
+		}
+		errs[seed] = err
+	}
+
+	var candidates []string
+	for _, s := range seeds {
+		resolved, err := ResolveAddrContext(ctx, s)
+		if err != nil {
+			fail(s, err)
+			continue
+		}
+		candidates = append(candidates, resolved...)
+	}
+	for _, node := range discovery.Nodes {
+		if node.Master && !node.HasFlag("myself") && !node.HasFlag("fail") && node.IP != "" {
+			candidates = append(candidates, node.Addr())
+		}
+	}
+
+	tried := map[string]bool{discovery.Seed: true}
+	for _, addr := range candidates {
+		if tried[addr] {
+			continue
+		}
+		tried[addr] = true
+		if err := ctx.Err(); err != nil {
+			return "", nil, err
+		}
+		nodes, err := ClusterNodesContext(ctx, manager.Client(addr))
+		if err != nil {
+			fail(addr, err)
+			continue
+		}
+		other := false
+		for _, node := range nodes {
+			other = other || node.HasFlag("myself") && node.ID != myself
+		}
+		if !other {
+			continue
+		}
+		return addr, CompareTopology(discovery.Nodes, nodes), nil
+	}
+
+	if len(failed) == 0 {
+		err = newError(fmt.Sprintf("No node other than %s is found to cross-check", discovery.Seed))
+		return "", nil, err
+	}
+	err = &UnreachableSeedsError{Seeds: failed, Errors: errs}
+	err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+	return "", nil, err
+}
+
+// CompareTopology returns differences of node set, roles, config epochs and slots between two topologies
+func CompareTopology(a []ClusterNode, b []ClusterNode) (diffs []string) {
+	index := func(nodes []ClusterNode) map[string]ClusterNode {
+		m := make(map[string]ClusterNode)
+		for _, node := range nodes {
+			m[node.ID] = node
+		}
+		return m
+	}
+	am, bm := index(a), index(b)
+
+	var ids []string
+	for id := range am {
+		ids = append(ids, id)
+	}
+	for id := range bm {
+		if _, ok := am[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		an, aok := am[id]
+		bn, bok := bm[id]
+		switch {
+		case !aok:
+			diffs = append(diffs, fmt.Sprintf("%s is known only by second seed", id))
+			continue
+		case !bok:
+			diffs = append(diffs, fmt.Sprintf("%s is known only by first seed", id))
+			continue
+		}
+		if af, bf := an.stateFlags(), bn.stateFlags(); af != bf {
+			diffs = append(diffs, fmt.Sprintf("%s flags differ: %s != %s", id, af, bf))
+		}
+		if an.SlaveOf != bn.SlaveOf {
+			diffs = append(diffs, fmt.Sprintf("%s slaveof differs: %s != %s", id, an.SlaveOf, bn.SlaveOf))
+		}
+		if an.ConfigEpoch != bn.ConfigEpoch {
+			diffs = append(diffs, fmt.Sprintf("%s config epoch differs: %d != %d", id, an.ConfigEpoch, bn.ConfigEpoch))
+		}
+		if as, bs := fmt.Sprint(an.stableSlots()), fmt.Sprint(bn.stableSlots()); as != bs {
+			diffs = append(diffs, fmt.Sprintf("%s slots differ: %s != %s", id, as, bs))
+		}
+	}
+	return diffs
+}
+
+// HasFlag returns true if node has flag
+func (node ClusterNode) HasFlag(flag string) bool {
+	for _, f := range node.Flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// stateFlags returns flags without 'myself' which differs by seed
+func (node ClusterNode) stateFlags() string {
+	var flags []string
+	for _, f := range node.Flags {
+		if f != "myself" {
+			flags = append(flags, f)
+		}
+	}
+	return strings.Join(flags, ",")
+}
+
+// stableSlots returns slots without importing and migrating slots which only node itself prints
+func (node ClusterNode) stableSlots() (slots []Slot) {
+	for _, slot := range node.Slots {
+		if slot.From == "" && slot.To == "" {
+			slots = append(slots, slot)
+		}
+	}
+	return slots
+}
@@ -0,0 +1,54 @@
+package rcc_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/kizkoh/rcc/rcc"
+	"github.com/kizkoh/rcc/rcc/rcctest"
+)
+
+func TestCrossCheck(t *testing.T) {
+	cluster, err := rcctest.NewEvenCluster(2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cluster.Close()
+	manager := rcc.NewManager(rcc.DefaultClientOptions)
+	defer manager.Close()
+	a, b := cluster.Servers[0], cluster.Servers[1]
+
+	discovery, err := rcc.DiscoverContext(context.Background(), manager, []string{a.Addr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// seeds naming node answered discovery by hostname are not cross-checked against it
+	seeds := []string{fmt.Sprintf("localhost:%d", a.Port), a.Addr}
+	seed, diffs, err := rcc.CrossCheckContext(context.Background(), manager, discovery, seeds)
+	if err != nil {
+		t.Fatalf("CrossCheck() error = %v", err)
+	}
+	if seed != b.Addr {
+		t.Errorf("CrossCheck() seed = %s, want %s", seed, b.Addr)
+	}
+	if len(diffs) != 0 {
+		t.Errorf("CrossCheck() diffs = %v, want none", diffs)
+	}
+
+	// node answered discovery is the only one
+	alone, err := rcctest.NewEvenCluster(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer alone.Close()
+	s := alone.Servers[0]
+	discovery, err = rcc.DiscoverContext(context.Background(), manager, []string{s.Addr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	seeds = []string{fmt.Sprintf("localhost:%d", s.Port)}
+	if seed, _, err := rcc.CrossCheckContext(context.Background(), manager, discovery, seeds); err == nil {
+		t.Errorf("CrossCheck() seed = %s, want error", seed)
+	}
+}
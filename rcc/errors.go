@@ -0,0 +1,142 @@
+package rcc
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Errors returned by rcc are wrapped with message, test them with errors.Is and errors.As
+var (
+	// ErrPortNotFound is returned in ParseError when node address has no port
+	ErrPortNotFound = errors.New("Port is not found")
+	// ErrMalformedLine is returned in ParseError when line has too few fields
+	ErrMalformedLine = errors.New("Malformed cluster node line")
+	// ErrMalformedSlot is returned in ParseError when slot is neither range nor importing or migrating slot
+	ErrMalformedSlot = errors.New("Malformed slot")
+	// ErrFieldNotFound is returned in ParseError when INFO or 'CLUSTER INFO' has no field needed
+	ErrFieldNotFound = errors.New("Field is not found")
+	// ErrMyselfNotFound is returned when 'CLUSTER NODES' has no myself node
+	ErrMyselfNotFound = errors.New("Myself is not found in cluster nodes")
+	// ErrHostNotFound is returned when hostname has no address
+	ErrHostNotFound = errors.New("No address is found for host")
+	// ErrUnknownCluster is returned when cluster name is not defined in config
+	ErrUnknownCluster = errors.New("Cluster is not defined in config")
+	// ErrSlotOpen is returned when plan is built while slot is importing or migrating
+	ErrSlotOpen = errors.New("Slot is importing or migrating")
+	// ErrMasterFailing is returned when plan needs slots of failing master
+	ErrMasterFailing = errors.New("Master owning slots is failing")
+	// ErrUnknownWeight is returned by RebalancePlan when weight of slot owned by master is unknown
+	ErrUnknownWeight = errors.New("Weight of slot is unknown")
+)
+
+// newError returns error of msg wrapped with name and version of rcc as other errors
+func newError(msg string) error {
+	return errors.Wrap(errors.New(msg), fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+}
+
+// wrapError returns sentinel err with detail wrapped with name and version of rcc, errors.Is finds err
+func wrapError(err error, detail string) error {
+	return errors.Wrap(&detailError{Err: err, Detail: detail}, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+}
+
+// detailError is sentinel error with detail of its case
+type detailError struct {
+	Err    error
+	Detail string
+}
+
+func (e *detailError) Error() string {
+	return fmt.Sprintf("%v: %s", e.Err, e.Detail)
+}
+
+// Unwrap returns sentinel error
+func (e *detailError) Unwrap() error {
+	return e.Err
+}
+
+// ParseError is error of 'CLUSTER NODES' or nodes.conf line
+type ParseError struct {
+	Line string
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%v: %s", e.Err, e.Line)
+}
+
+// Unwrap returns cause of parse error
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// NodeNotEmptyError is returned by AssertEmptyNode, node knows other nodes or has keys
+type NodeNotEmptyError struct {
+	KnownNodes uint64
+	Keys       uint64
+}
+
+func (e *NodeNotEmptyError) Error() string {
+	return fmt.Sprintf("node is not empty, either the node already knows other nodes (cluster_known_nodes:%d) or contains some key in database 0 (keys:%d)", e.KnownNodes, e.Keys)
+}
+
+// AmbiguousHostError is returned when hostname maps to several nodes, Nodes is addresses keyed by node ID
+type AmbiguousHostError struct {
+	Addr  string
+	Nodes map[string][]string
+}
+
+func (e *AmbiguousHostError) Error() string {
+	var ids []string
+	for id := range e.Nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	var nodes []string
+	for _, id := range ids {
+		nodes = append(nodes, fmt.Sprintf("%s at %s", id, strings.Join(e.Nodes[id], ",")))
+	}
+	return fmt.Sprintf("%s maps to %d nodes (%s), give HOST:PORT of one node", e.Addr, len(e.Nodes), strings.Join(nodes, "; "))
+}
+
+// UnreachableSeedsError is returned when no seed answers, Errors is error keyed by seed in tried order
+type UnreachableSeedsError struct {
+	Seeds  []string
+	Errors map[string]error
+}
+
+func (e *UnreachableSeedsError) Error() string {
+	var msgs []string
+	for _, seed := range e.Seeds {
+		msgs = append(msgs, fmt.Sprintf("%s: %v", seed, errors.Cause(e.Errors[seed])))
+	}
+	return fmt.Sprintf("No seed is reachable (%s)", strings.Join(msgs, ", "))
+}
+
+// PreconditionError is returned by Plan.Check when live topology differs from one plan relies on
+type PreconditionError struct {
+	Precondition Precondition
+	Reason       string
+}
+
+func (e *PreconditionError) Error() string {
+	c := e.Precondition
+	target := c.ID
+	if target == "" {
+		target = c.Addr
+	}
+	return fmt.Sprintf("Precondition %s of %s is not met, %s", c.Type, target, e.Reason)
+}
+
+// IsNetworkError returns true if err is caused by network failure or timeout rather than reply of node
+func IsNetworkError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,58 @@
+package rcc
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ParseInfo parse 'INFO' or 'CLUSTER INFO' result into fields, section headers are skipped
+func ParseInfo(resp string) map[string]string {
+	fields := make(map[string]string)
+	for _, line := range strings.Split(resp, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		record := strings.SplitN(line, ":", 2)
+		if len(record) < 2 {
+			continue
+		}
+		fields[record[0]] = record[1]
+	}
+	return fields
+}
+
+// Keyspace is keys of database in 'INFO keyspace'
+type Keyspace struct {
+	Keys    uint64
+	Expires uint64
+}
+
+// ParseKeyspace parse 'keys=1,expires=0,avg_ttl=0' value of 'INFO keyspace', empty value is no key
+func ParseKeyspace(value string) (keyspace Keyspace, err error) {
+	if value == "" {
+		return keyspace, nil
+	}
+	for _, kv := range strings.Split(value, ",") {
+		record := strings.SplitN(kv, "=", 2)
+		if len(record) < 2 {
+			continue
+		}
+		var v *uint64
+		switch record[0] {
+		case "keys":
+			v = &keyspace.Keys
+		case "expires":
+			v = &keyspace.Expires
+		default:
+			continue
+		}
+		*v, err = strconv.ParseUint(record[1], 10, 64)
+		if err != nil {
+			return keyspace, errors.WithStack(&ParseError{Line: value, Err: err})
+		}
+	}
+	return keyspace, nil
+}
@@ -0,0 +1,100 @@
+package rcc
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// SlotKey is key read from slot
+type SlotKey struct {
+	Slot int    `json:"slot"`
+	Key  string `json:"key"`
+	Type string `json:"type,omitempty"`
+	PTTL *int64 `json:"pttl,omitempty"` // -1 for key without expire
+}
+
+// ExportOptions is options of ExportKeys
+type ExportOptions struct {
+	Limit   int  // keys read at most from slot, every key when zero
+	Max     int  // keys read at most in one reply, DefaultExportOptions.Max when zero
+	Batch   int  // TYPE and PTTL pipelined in one round trip, DefaultExportOptions.Batch when zero
+	Details bool // read type and TTL of key
+}
+
+// DefaultExportOptions is default options of ExportKeys
+var DefaultExportOptions = ExportOptions{
+	Max:   10000,
+	Batch: 1000,
+}
+
+// ExportKeys calls fn with keys of slots in order of slots, keys of slot are read from client of its owner
+//
+// 'CLUSTER GETKEYSINSLOT' has no cursor, so that keys are paged by slot and keys of one slot are read in one reply
+// of at most opt.Limit keys. Reply is bounded by opt.Max keys, and keys of slot over it are not read but warned,
+// as the same first keys would be read again. Key deleted while its type is read is skipped, and keys are not
+// consistent snapshot. Export stops at first error of fn.
+func ExportKeys(ctx context.Context, client func(slot int) (Client, error), slots []int, opt ExportOptions, fn func(SlotKey) error) error {
+	if opt.Max <= 0 {
+		opt.Max = DefaultExportOptions.Max
+	}
+	if opt.Batch <= 0 {
+		opt.Batch = DefaultExportOptions.Batch
+	}
+	for _, slot := range slots {
+		c, err := client(slot)
+		if err != nil {
+			return err
+		}
+		count := int64(opt.Limit)
+		if count <= 0 || count > int64(opt.Max) {
+			n, err := c.ClusterCountKeysInSlot(ctx, slot)
+			if err != nil {
+				return errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+			}
+			if count <= 0 || n < count {
+				count = n
+			}
+		}
+		if count > int64(opt.Max) {
+			DefaultLogger.Warn("keys of slot over max are not read", "slot", slot, "keys", count, "max", opt.Max)
+			count = int64(opt.Max)
+		}
+		if count == 0 {
+			continue
+		}
+		keys, err := c.ClusterGetKeysInSlot(ctx, slot, int(count))
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+		}
+		if !opt.Details {
+			for _, key := range keys {
+				if err := fn(SlotKey{Slot: slot, Key: key}); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		for start := 0; start < len(keys); start += opt.Batch {
+			end := start + opt.Batch
+			if end > len(keys) {
+				end = len(keys)
+			}
+			types, pttls, err := c.TypeAndPTTL(ctx, keys[start:end])
+			if err != nil {
+				return errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+			}
+			for i, key := range keys[start:end] {
+				if types[i] == "none" {
+					continue
+				}
+				pttl := pttls[i]
+				if err := fn(SlotKey{Slot: slot, Key: key, Type: types[i], PTTL: &pttl}); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package rcc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Level is log level
+type Level int
+
+// log levels, message below level of logger is discarded
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (level Level) String() string {
+	if level < LevelDebug || level > LevelError {
+		return fmt.Sprintf("level(%d)", int(level))
+	}
+	return levelNames[level]
+}
+
+// ParseLevel returns level named debug, info, warn or error
+func ParseLevel(name string) (Level, error) {
+	for i, n := range levelNames {
+		if strings.EqualFold(name, n) {
+			return Level(i), nil
+		}
+	}
+	err := errors.New(fmt.Sprintf("Unknown log level '%s'", name))
+	err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+	return LevelInfo, err
+}
+
+// Logger writes leveled messages with key value pairs as logfmt text or JSON lines
+type Logger struct {
+	mu    sync.Mutex
+	out   io.Writer
+	level Level
+	json  bool
+}
+
+// NewLogger returns logger writing messages at level or above into out
+func NewLogger(out io.Writer, level Level, json bool) *Logger {
+	return &Logger{out: out, level: level, json: json}
+}
+
+// DefaultLogger is logger used by rcc, commands sent to nodes are logged at debug level
+var DefaultLogger = NewLogger(os.Stderr, LevelInfo, false)
+
+// Enabled reports whether message at level is written
+func (l *Logger) Enabled(level Level) bool {
+	return l != nil && level >= l.level
+}
+
+// Debug writes message at debug level, kv is alternating keys and values
+func (l *Logger) Debug(msg string, kv ...interface{}) {
+	l.Log(LevelDebug, msg, kv...)
+}
+
+// Info writes message at info level
+func (l *Logger) Info(msg string, kv ...interface{}) {
+	l.Log(LevelInfo, msg, kv...)
+}
+
+// Warn writes message at warn level
+func (l *Logger) Warn(msg string, kv ...interface{}) {
+	l.Log(LevelWarn, msg, kv...)
+}
+
+// Error writes message at error level
+func (l *Logger) Error(msg string, kv ...interface{}) {
+	l.Log(LevelError, msg, kv...)
+}
+
+// Log writes message at level with key value pairs
+func (l *Logger) Log(level Level, msg string, kv ...interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+	if len(kv)%2 != 0 {
+		kv = append(kv, "(MISSING)")
+	}
+
+	var b bytes.Buffer
+	now := time.Now().Format(time.RFC3339Nano)
+	if l.json {
+		b.WriteString("{")
+		writeJSONField(&b, "time", now)
+		b.WriteString(",")
+		writeJSONField(&b, "level", level.String())
+		b.WriteString(",")
+		writeJSONField(&b, "msg", msg)
+		for i := 0; i < len(kv); i += 2 {
+			b.WriteString(",")
+			writeJSONField(&b, fmt.Sprint(kv[i]), logValue(kv[i+1]))
+		}
+		b.WriteString("}\n")
+	} else {
+		fmt.Fprintf(&b, "time=%s level=%s msg=%s", now, level, quoteText(msg))
+		for i := 0; i < len(kv); i += 2 {
+			fmt.Fprintf(&b, " %s=%s", fmt.Sprint(kv[i]), quoteText(fmt.Sprint(logValue(kv[i+1]))))
+		}
+		b.WriteString("\n")
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.out.Write(b.Bytes())
+}
+
+// logValue converts error, duration and stringer into string, other values are written as they are
+func logValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case error:
+		return v.Error()
+	case time.Duration:
+		return v.String()
+	case fmt.Stringer:
+		return v.String()
+	}
+	return v
+}
+
+func writeJSONField(b *bytes.Buffer, key string, value interface{}) {
+	k, _ := json.Marshal(key)
+	v, err := json.Marshal(value)
+	if err != nil {
+		v, _ = json.Marshal(fmt.Sprint(value))
+	}
+	b.Write(k)
+	b.WriteString(":")
+	b.Write(v)
+}
+
+// quoteText quotes logfmt value containing space, quote or equal sign
+func quoteText(s string) string {
+	if s == "" || strings.ContainsAny(s, " \t\r\n\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// commandArgs returns command line of args, password of AUTH, HELLO and MIGRATE is masked
+func commandArgs(args []interface{}) string {
+	words := make([]string, len(args))
+	for i, arg := range args {
+		words[i] = fmt.Sprint(arg)
+	}
+	if len(words) > 0 {
+		switch strings.ToLower(words[0]) {
+		case "auth":
+			if len(words) > 1 {
+				words[len(words)-1] = "********"
+			}
+		case "hello":
+			for i := 1; i+2 < len(words); i++ {
+				if strings.EqualFold(words[i], "auth") {
+					words[i+2] = "********"
+				}
+			}
+		case "migrate":
+			// keys following KEYS may be named auth
+			for i := 6; i < len(words) && !strings.EqualFold(words[i], "keys"); i++ {
+				switch {
+				case strings.EqualFold(words[i], "auth") && i+1 < len(words):
+					words[i+1] = "********"
+				case strings.EqualFold(words[i], "auth2") && i+2 < len(words):
+					words[i+2] = "********"
+				}
+			}
+		}
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,24 @@
+package rcc
+
+import "testing"
+
+func TestCommandArgs(t *testing.T) {
+	tests := []struct {
+		args []interface{}
+		want string
+	}{
+		{[]interface{}{"auth", "secret"}, "auth ********"},
+		{[]interface{}{"AUTH", "admin", "secret"}, "AUTH admin ********"},
+		{[]interface{}{"hello", 3, "auth", "admin", "secret"}, "hello 3 auth admin ********"},
+		{[]interface{}{"migrate", "127.0.0.1", "7000", "", 0, 60000, "auth", "secret", "keys", "a"}, "migrate 127.0.0.1 7000  0 60000 auth ******** keys a"},
+		{[]interface{}{"migrate", "127.0.0.1", "7000", "", 0, 60000, "auth2", "admin", "secret", "keys", "a"}, "migrate 127.0.0.1 7000  0 60000 auth2 admin ******** keys a"},
+		// keys named auth are not masked
+		{[]interface{}{"migrate", "127.0.0.1", "7000", "", 0, 60000, "keys", "auth", "x"}, "migrate 127.0.0.1 7000  0 60000 keys auth x"},
+		{[]interface{}{"cluster", "setslot", 0, "node", "abc"}, "cluster setslot 0 node abc"},
+	}
+	for _, tt := range tests {
+		if got := commandArgs(tt.args); got != tt.want {
+			t.Errorf("commandArgs(%v) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
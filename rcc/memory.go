@@ -0,0 +1,207 @@
+package rcc
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// SampleOptions is options of EstimateMemory
+type SampleOptions struct {
+	Rate    float64 // fraction of keys sampled in slot, DefaultSampleOptions.Rate when zero
+	Min     int     // keys sampled at least in slot, every key of smaller slot is measured
+	Max     int     // keys sampled at most in slot, DefaultSampleOptions.Max when zero
+	Workers int     // shards sampled concurrently, DefaultSampleOptions.Workers when zero
+}
+
+// DefaultSampleOptions is default options of EstimateMemory
+var DefaultSampleOptions = SampleOptions{
+	Rate:    0.01,
+	Min:     20,
+	Max:     1000,
+	Workers: 8,
+}
+
+// SlotMemory is bytes of slot estimated from sampled keys
+type SlotMemory struct {
+	Slot    int   `json:"slot"`
+	Keys    int64 `json:"keys"`
+	Sampled int   `json:"sampled"`
+	Bytes   int64 `json:"bytes"`  // -1 for slot not estimated
+	Margin  int64 `json:"margin"` // half width of 95% confidence interval of bytes, -1 for slot of single sampled key
+}
+
+// marginZ is quantile of normal distribution of 95% confidence
+const marginZ = 1.96
+
+// EstimateMemory returns bytes of every slot owned by healthy master in cluster, counts is keys of every slot
+//
+// Keys of slot are sampled by 'CLUSTER GETKEYSINSLOT', measured by 'MEMORY USAGE' and mean of them is
+// extrapolated to keys of slot. Margin is taken from standard deviation of measured keys as if they were sampled
+// at random. GETKEYSINSLOT returns the same first keys in order of hash table rather than random keys, so that
+// estimate is biased beyond margin when size of key depends on its place.
+// Slot not owned, not counted, of which every sampled key vanished or not estimated because of error has Bytes of -1.
+// Estimates of other shards are returned with first error.
+func EstimateMemory(ctx context.Context, manager *Manager, cluster []ClusterNode, counts []int64, opt SampleOptions) (slots []SlotMemory, err error) {
+	if opt.Rate <= 0 {
+		opt.Rate = DefaultSampleOptions.Rate
+	}
+	if opt.Max <= 0 {
+		opt.Max = DefaultSampleOptions.Max
+	}
+	if opt.Workers <= 0 {
+		opt.Workers = DefaultSampleOptions.Workers
+	}
+	slots = make([]SlotMemory, SlotCount)
+	for i := range slots {
+		slots[i] = SlotMemory{Slot: i, Keys: counts[i], Bytes: -1}
+	}
+
+	shards := make(chan ClusterNode)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < opt.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for node := range shards {
+				if serr := estimateShard(ctx, manager.NodeClient(node), node, opt, slots); serr != nil {
+					mu.Lock()
+					if err == nil {
+						err = serr
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, node := range cluster {
+		if node.Slave || node.HasFlag("fail") || node.HasFlag("noaddr") {
+			continue
+		}
+		shards <- node
+	}
+	close(shards)
+	wg.Wait()
+	return slots, err
+}
+
+// estimateShard estimates bytes of slots owned by master node, each worker writes its own slots
+func estimateShard(ctx context.Context, client Client, node ClusterNode, opt SampleOptions, slots []SlotMemory) error {
+	for _, slot := range node.Slots {
+		if slot.From != "" || slot.To != "" {
+			continue
+		}
+		for i := slot.Start; i <= slot.End && i < SlotCount; i++ {
+			if slots[i].Keys < 0 {
+				continue
+			}
+			if err := estimateSlot(ctx, client, opt, &slots[i]); err != nil {
+				return errors.Wrap(err, fmt.Sprintf("%v-%v failed: %s", App.Name, App.Version, node.Addr()))
+			}
+		}
+	}
+	return nil
+}
+
+// estimateSlotRetries is samples taken again when every sampled key of slot vanished before measured
+const estimateSlotRetries = 1
+
+// estimateSlot samples keys of slot and sets estimated bytes, bytes stay -1 when every sampled key vanished
+func estimateSlot(ctx context.Context, client Client, opt SampleOptions, slot *SlotMemory) error {
+	if slot.Keys == 0 {
+		slot.Bytes = 0
+		return nil
+	}
+	n := int(math.Ceil(opt.Rate * float64(slot.Keys)))
+	if n < opt.Min {
+		n = opt.Min
+	}
+	if n > opt.Max {
+		n = opt.Max
+	}
+	if int64(n) > slot.Keys {
+		n = int(slot.Keys)
+	}
+	for i := 0; i <= estimateSlotRetries; i++ {
+		keys, err := client.ClusterGetKeysInSlot(ctx, slot.Slot, n)
+		if err != nil {
+			return err
+		}
+		sizes, err := client.MemoryUsage(ctx, keys)
+		if err != nil {
+			return err
+		}
+
+		// keys expired or deleted since sampled are skipped
+		var measured []float64
+		var sum float64
+		for _, size := range sizes {
+			if size < 0 {
+				continue
+			}
+			measured = append(measured, float64(size))
+			sum += float64(size)
+		}
+		if len(measured) == 0 {
+			continue
+		}
+		m := len(measured)
+		mean := sum / float64(m)
+		slot.Sampled = m
+		slot.Bytes = int64(math.Round(mean * float64(slot.Keys)))
+		slot.Margin = sampleMargin(measured, mean, slot.Keys)
+		return nil
+	}
+	return nil
+}
+
+// sampleMargin returns margin of bytes of n keys extrapolated from mean of measured keys
+//
+// Standard error of mean is corrected for finite n, so that margin is 0 when every key is measured.
+func sampleMargin(measured []float64, mean float64, n int64) int64 {
+	m := len(measured)
+	if int64(m) >= n {
+		return 0
+	}
+	if m < 2 {
+		return -1
+	}
+	var sq float64
+	for _, v := range measured {
+		sq += (v - mean) * (v - mean)
+	}
+	stderr := math.Sqrt(sq/float64(m-1)/float64(m)) * math.Sqrt(float64(n-int64(m))/float64(n-1))
+	return int64(math.Round(marginZ * stderr * float64(n)))
+}
+
+// SumSlotMemory returns bytes of estimated slots, slots not estimated are skipped
+func SumSlotMemory(slots []SlotMemory) (bytes int64) {
+	for _, slot := range slots {
+		if slot.Bytes < 0 {
+			continue
+		}
+		bytes += slot.Bytes
+	}
+	return bytes
+}
+
+// MarginSlotMemory returns margin of sum of estimated slots, -1 when margin of any of them is unknown
+//
+// Estimates of slots are independent, so that their margins add in squares.
+func MarginSlotMemory(slots []SlotMemory) int64 {
+	var sq float64
+	for _, slot := range slots {
+		if slot.Bytes < 0 {
+			continue
+		}
+		if slot.Margin < 0 {
+			return -1
+		}
+		sq += float64(slot.Margin) * float64(slot.Margin)
+	}
+	return int64(math.Round(math.Sqrt(sq)))
+}
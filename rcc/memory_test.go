@@ -0,0 +1,105 @@
+package rcc
+
+import (
+	"context"
+	"testing"
+)
+
+// sampleClient is Client answering GETKEYSINSLOT and MEMORY USAGE from scripted replies
+type sampleClient struct {
+	Client
+	keys  [][]string // reply of every GETKEYSINSLOT
+	sizes [][]int64  // reply of every MEMORY USAGE
+	calls int
+}
+
+func (c *sampleClient) ClusterGetKeysInSlot(ctx context.Context, slot int, count int) ([]string, error) {
+	keys := c.keys[c.calls]
+	if len(keys) > count {
+		keys = keys[:count]
+	}
+	return keys, nil
+}
+
+func (c *sampleClient) MemoryUsage(ctx context.Context, keys []string) ([]int64, error) {
+	sizes := c.sizes[c.calls][:len(keys)]
+	c.calls++
+	return sizes, nil
+}
+
+func TestEstimateSlot(t *testing.T) {
+	opt := SampleOptions{Rate: 0.01, Min: 3, Max: 3}
+	tests := []struct {
+		name        string
+		keys        int64
+		replies     [][]string
+		sizes       [][]int64
+		wantBytes   int64
+		wantMargin  int64
+		wantSampled int
+		wantCalls   int
+	}{
+		{name: "empty slot", keys: 0, wantBytes: 0},
+		{
+			name: "every key is measured", keys: 2,
+			replies: [][]string{{"a", "b"}}, sizes: [][]int64{{100, 300}},
+			wantBytes: 400, wantSampled: 2, wantCalls: 1,
+		},
+		{
+			name: "mean is extrapolated", keys: 100,
+			replies: [][]string{{"a", "b", "c", "d"}}, sizes: [][]int64{{100, 200, 300, 400}},
+			wantBytes: 20000, wantMargin: 11201, wantSampled: 3, wantCalls: 1,
+		},
+		{
+			name: "vanished key is skipped", keys: 10,
+			replies: [][]string{{"a", "b", "c"}}, sizes: [][]int64{{100, -1, 300}},
+			wantBytes: 2000, wantMargin: 1848, wantSampled: 2, wantCalls: 1,
+		},
+		{
+			name: "margin of single measured key is unknown", keys: 10,
+			replies: [][]string{{"a", "b"}}, sizes: [][]int64{{-1, 100}},
+			wantBytes: 1000, wantMargin: -1, wantSampled: 1, wantCalls: 1,
+		},
+		{
+			name: "slot is sampled again when every key vanished", keys: 10,
+			replies: [][]string{{"a", "b"}, {"c", "d"}}, sizes: [][]int64{{-1, -1}, {50, 150}},
+			wantBytes: 1000, wantMargin: 924, wantSampled: 2, wantCalls: 2,
+		},
+		{
+			name: "slot is unknown when keys keep vanishing", keys: 10,
+			replies: [][]string{{"a", "b"}, {"c"}}, sizes: [][]int64{{-1, -1}, {-1}},
+			wantBytes: -1, wantSampled: 0, wantCalls: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &sampleClient{keys: tt.replies, sizes: tt.sizes}
+			slot := SlotMemory{Slot: 1, Keys: tt.keys, Bytes: -1}
+			if err := estimateSlot(context.Background(), client, opt, &slot); err != nil {
+				t.Fatal(err)
+			}
+			if slot.Bytes != tt.wantBytes || slot.Margin != tt.wantMargin || slot.Sampled != tt.wantSampled || client.calls != tt.wantCalls {
+				t.Errorf("estimateSlot() = bytes %d+-%d sampled %d in %d samples, want bytes %d+-%d sampled %d in %d samples",
+					slot.Bytes, slot.Margin, slot.Sampled, client.calls, tt.wantBytes, tt.wantMargin, tt.wantSampled, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestSumSlotMemory(t *testing.T) {
+	slots := []SlotMemory{{Bytes: 100}, {Bytes: -1}, {Bytes: 0}, {Bytes: 250}}
+	if got := SumSlotMemory(slots); got != 350 {
+		t.Errorf("SumSlotMemory() = %d, want 350", got)
+	}
+}
+
+func TestMarginSlotMemory(t *testing.T) {
+	slots := []SlotMemory{{Bytes: 100, Margin: 30}, {Bytes: -1, Margin: -1}, {Bytes: 0}, {Bytes: 250, Margin: 40}}
+	if got := MarginSlotMemory(slots); got != 50 {
+		t.Errorf("MarginSlotMemory() = %d, want 50", got)
+	}
+	slots = append(slots, SlotMemory{Bytes: 10, Margin: -1})
+	if got := MarginSlotMemory(slots); got != -1 {
+		t.Errorf("MarginSlotMemory() with unknown margin = %d, want -1", got)
+	}
+}
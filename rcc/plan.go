@@ -0,0 +1,322 @@
+package rcc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// PlanVersion is version of plan file format
+const PlanVersion = 1
+
+// Plan is sequence of steps of mutating command, written by --plan and run by 'rcc apply' after review
+//
+// Preconditions are checked against live topology before steps run, so that plan computed from stale
+// topology is never applied.
+type Plan struct {
+	Version       int            `json:"version"`
+	Command       string         `json:"command"`
+	Cluster       string         `json:"cluster"` // address of node topology is read from
+	Created       time.Time      `json:"created"`
+	Preconditions []Precondition `json:"preconditions"`
+	Steps         []Step         `json:"steps"`
+}
+
+// Precondition types
+const (
+	// PreconditionNode requires node of ID to have role, master and config epoch
+	PreconditionNode = "node"
+	// PreconditionOwner requires slots to be owned by node of ID
+	PreconditionOwner = "owner"
+	// PreconditionEmpty requires node at address to be empty and to know no other node
+	PreconditionEmpty = "empty"
+)
+
+// Precondition is topology plan relies on, empty field is not checked
+type Precondition struct {
+	Type        string   `json:"type"`
+	ID          string   `json:"id,omitempty"`
+	Addr        string   `json:"addr,omitempty"`
+	Role        string   `json:"role,omitempty"` // master or slave
+	SlaveOf     string   `json:"slaveof,omitempty"`
+	ConfigEpoch *uint64  `json:"config_epoch,omitempty"`
+	Slots       []string `json:"slots,omitempty"` // slot ranges such as "0-5460"
+}
+
+// Step types
+const (
+	// StepCommand sends Args to node at Node
+	StepCommand = "command"
+	// StepWaitJoin waits until node at Node knows node of ID
+	StepWaitJoin = "wait-join"
+	// StepMigrateSlot moves Slots and their keys from master From to master To
+	StepMigrateSlot = "migrate-slot"
+)
+
+// DefaultJoinTimeout is timeout of wait-join step without timeout
+var DefaultJoinTimeout = 30 * time.Second
+
+// Step is one step of plan
+type Step struct {
+	Type    string   `json:"type"`
+	Node    string   `json:"node,omitempty"`
+	Args    []string `json:"args,omitempty"`
+	ID      string   `json:"id,omitempty"`
+	Timeout string   `json:"timeout,omitempty"`
+	Slots   string   `json:"slots,omitempty"` // slot range such as "0-5460" or single slot
+	From    *NodeRef `json:"from,omitempty"`
+	To      *NodeRef `json:"to,omitempty"`
+}
+
+// NewPlan returns empty plan of command line reading topology from node at cluster
+func NewPlan(command string, cluster string) *Plan {
+	return &Plan{
+		Version:       PlanVersion,
+		Command:       command,
+		Cluster:       cluster,
+		Created:       time.Now(),
+		Preconditions: []Precondition{},
+		Steps:         []Step{},
+	}
+}
+
+// Require appends precondition
+func (plan *Plan) Require(c Precondition) {
+	plan.Preconditions = append(plan.Preconditions, c)
+}
+
+// Add appends step
+func (plan *Plan) Add(step Step) {
+	plan.Steps = append(plan.Steps, step)
+}
+
+// AddCommand appends command step sending args to node at addr
+func (plan *Plan) AddCommand(addr string, args ...string) {
+	plan.Add(Step{Type: StepCommand, Node: addr, Args: args})
+}
+
+// ReadPlan read plan file
+func ReadPlan(path string) (plan *Plan, err error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+		return nil, err
+	}
+	plan = &Plan{}
+	if err := json.Unmarshal(b, plan); err != nil {
+		err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: %s", App.Name, App.Version, path))
+		return nil, err
+	}
+	if plan.Version != PlanVersion {
+		err = errors.New(fmt.Sprintf("Plan version %d is not supported", plan.Version))
+		err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: %s", App.Name, App.Version, path))
+		return nil, err
+	}
+	return plan, nil
+}
+
+// Write write plan file
+func (plan *Plan) Write(path string) error {
+	b, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+	}
+	if err := ioutil.WriteFile(path, append(b, '\n'), 0644); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+	}
+	return nil
+}
+
+// Check checks every precondition against live topology, *PreconditionError is returned for first unmet one
+func (plan *Plan) Check(ctx context.Context, manager *Manager) error {
+	cluster, err := ClusterNodesContext(ctx, manager.Client(plan.Cluster))
+	if err != nil {
+		return err
+	}
+	nodes := make(map[string]ClusterNode)
+	var owner [SlotCount]string
+	for _, node := range cluster {
+		nodes[node.ID] = node
+		for _, slot := range node.Slots {
+			if slot.From != "" || slot.To != "" {
+				continue
+			}
+			for i := slot.Start; i <= slot.End && i < SlotCount; i++ {
+				owner[i] = node.ID
+			}
+		}
+	}
+
+	for _, c := range plan.Preconditions {
+		var reason string
+		switch c.Type {
+		case PreconditionNode:
+			node, ok := nodes[c.ID]
+			switch {
+			case !ok:
+				reason = "node is not found"
+			case node.HasFlag("fail") || node.HasFlag("pfail"):
+				reason = "node is failing"
+			case c.Role != "" && c.Role != nodeRole(node):
+				reason = fmt.Sprintf("node is %s", nodeRole(node))
+			case c.SlaveOf != "" && c.SlaveOf != node.SlaveOf:
+				reason = fmt.Sprintf("node is slave of %s", node.SlaveOf)
+			case c.ConfigEpoch != nil && *c.ConfigEpoch != node.ConfigEpoch:
+				reason = fmt.Sprintf("config epoch is %d", node.ConfigEpoch)
+			}
+		case PreconditionOwner:
+			for _, r := range c.Slots {
+				start, end, err := ParseSlotRange(r)
+				if err != nil {
+					return err
+				}
+				for i := start; i <= end; i++ {
+					if owner[i] != c.ID {
+						reason = fmt.Sprintf("slot %d is owned by %q", i, owner[i])
+						break
+					}
+				}
+				if reason != "" {
+					break
+				}
+			}
+		case PreconditionEmpty:
+			if err := AssertEmptyNodeContext(ctx, manager.Client(c.Addr)); err != nil {
+				var notEmpty *NodeNotEmptyError
+				if !errors.As(err, &notEmpty) {
+					return err
+				}
+				reason = notEmpty.Error()
+			}
+		default:
+			reason = "unknown precondition type"
+		}
+		if reason != "" {
+			err := &PreconditionError{Precondition: c, Reason: reason}
+			return errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+		}
+	}
+	return nil
+}
+
+// Run runs steps in order without checking preconditions, and stops at first failed step
+func (plan *Plan) Run(ctx context.Context, manager *Manager) error {
+	for i, step := range plan.Steps {
+		DefaultLogger.Info("plan step", "step", fmt.Sprintf("%d/%d", i+1, len(plan.Steps)), "run", step)
+		if err := step.Run(ctx, manager); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("step %d (%s)", i+1, step))
+		}
+	}
+	return nil
+}
+
+// Apply checks preconditions and runs steps
+func (plan *Plan) Apply(ctx context.Context, manager *Manager) error {
+	if err := plan.Check(ctx, manager); err != nil {
+		return err
+	}
+	return plan.Run(ctx, manager)
+}
+
+// Run runs step
+func (step Step) Run(ctx context.Context, manager *Manager) error {
+	switch step.Type {
+	case StepCommand:
+		if len(step.Args) == 0 {
+			break
+		}
+		client, ok := manager.Client(step.Node).(Commander)
+		if !ok {
+			err := errors.New(fmt.Sprintf("Client of %s can not run command %s", step.Node, step.Args[0]))
+			return errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+		}
+		if _, err := client.Do(ctx, stringArgs(step.Args)...); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("%v-%v failed: %s", App.Name, App.Version, step.Node))
+		}
+		return nil
+	case StepWaitJoin:
+		timeout := DefaultJoinTimeout
+		if step.Timeout != "" {
+			var err error
+			if timeout, err = time.ParseDuration(step.Timeout); err != nil {
+				return errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+			}
+		}
+		return WaitJoin(ctx, manager.Client(step.Node), step.ID, timeout)
+	case StepMigrateSlot:
+		if step.From == nil || step.To == nil {
+			break
+		}
+		start, end, err := ParseSlotRange(step.Slots)
+		if err != nil {
+			return err
+		}
+		for slot := start; slot <= end; slot++ {
+			if err := MigrateSlot(ctx, manager, slot, *step.From, *step.To); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	err := errors.New(fmt.Sprintf("Malformed %q step", step.Type))
+	return errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+}
+
+func (step Step) String() string {
+	switch step.Type {
+	case StepCommand:
+		return fmt.Sprintf("%s: %s", step.Node, commandArgs(stringArgs(step.Args)))
+	case StepWaitJoin:
+		return fmt.Sprintf("%s: wait until %s joins", step.Node, step.ID)
+	case StepMigrateSlot:
+		if step.From != nil && step.To != nil {
+			return fmt.Sprintf("migrate slots %s from %s to %s", step.Slots, step.From.Addr, step.To.Addr)
+		}
+	}
+	return step.Type
+}
+
+func stringArgs(args []string) []interface{} {
+	v := make([]interface{}, len(args))
+	for i, arg := range args {
+		v[i] = arg
+	}
+	return v
+}
+
+// nodeRole returns master or slave
+func nodeRole(node ClusterNode) string {
+	if node.Slave {
+		return "slave"
+	}
+	return "master"
+}
+
+// FormatSlotRange returns slot range as "START-END", or "SLOT" for single slot
+func FormatSlotRange(start int, end int) string {
+	if start == end {
+		return strconv.Itoa(start)
+	}
+	return fmt.Sprintf("%d-%d", start, end)
+}
+
+// ParseSlotRange parses "START-END" or "SLOT"
+func ParseSlotRange(s string) (start int, end int, err error) {
+	parts := strings.SplitN(s, "-", 2)
+	start, err = strconv.Atoi(parts[0])
+	end = start
+	if err == nil && len(parts) == 2 {
+		end, err = strconv.Atoi(parts[1])
+	}
+	if err != nil || start < 0 || end < start || end >= SlotCount {
+		err = errors.Wrap(&ParseError{Line: s, Err: ErrMalformedSlot}, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+		return 0, 0, err
+	}
+	return start, end, nil
+}
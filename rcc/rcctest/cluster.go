@@ -0,0 +1,313 @@
+// Package rcctest provides fake redis cluster nodes on localhost for testing rcc commands end to end.
+//
+// Nodes answer CLUSTER NODES/INFO/MEET/REPLICATE/FORGET/RESET/COUNTKEYSINSLOT/GETKEYSINSLOT/SETSLOT, INFO, MIGRATE
+// and basic key commands from scripted topology. Gossip is instant, a node knows met nodes as soon as CLUSTER MEET
+// returns.
+package rcctest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/kizkoh/rcc/rcc"
+)
+
+// world is state shared by every node of fake cluster
+type world struct {
+	mu      sync.Mutex
+	servers []*Server
+	owner   [rcc.SlotCount]string // node ID owning slot
+}
+
+func (w *world) server(id string) *Server {
+	for _, s := range w.servers {
+		if s.ID == id {
+			return s
+		}
+	}
+	return nil
+}
+
+func (w *world) serverByAddr(addr string) *Server {
+	for _, s := range w.servers {
+		if s.Addr == addr {
+			return s
+		}
+	}
+	return nil
+}
+
+func (w *world) maxEpoch() (epoch uint64) {
+	for _, s := range w.servers {
+		if s.epoch > epoch {
+			epoch = s.epoch
+		}
+	}
+	return epoch
+}
+
+// Spec is node of scripted topology
+type Spec struct {
+	ID      string            // node ID, random 40 hex characters when empty
+	SlaveOf string            // master node ID, empty for master
+	Slots   []rcc.Slot        // slots owned by master
+	Flags   []string          // extra flags such as "fail" or "pfail"
+	Epoch   uint64            // config epoch of master, masters are numbered from 1 when zero
+	Keys    map[string]string // keys of master, slaves copy keys of master
+	Alone   bool              // node does not know other nodes, such as empty node to be added
+}
+
+// Cluster is fake redis cluster
+type Cluster struct {
+	Servers []*Server
+
+	world *world
+}
+
+// NewCluster starts nodes of specs, nodes except Alone ones know each other
+func NewCluster(specs ...Spec) (cluster *Cluster, err error) {
+	c := &Cluster{world: &world{}}
+	defer func() {
+		if err != nil {
+			c.Close()
+		}
+	}()
+
+	for _, spec := range specs {
+		if _, err := c.start(spec); err != nil {
+			return nil, err
+		}
+	}
+
+	w := c.world
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	known := make(map[string]bool)
+	for i, s := range c.Servers {
+		if !specs[i].Alone {
+			known[s.ID] = true
+		}
+	}
+	for i, s := range c.Servers {
+		if !specs[i].Alone {
+			s.known = copyKnown(known)
+		}
+	}
+	for i, s := range c.Servers {
+		spec := specs[i]
+		if spec.SlaveOf == "" {
+			continue
+		}
+		master := w.server(spec.SlaveOf)
+		if master == nil || !master.master {
+			return nil, fmt.Errorf("rcctest: master %s of %s is not found", spec.SlaveOf, s.ID)
+		}
+		s.master = false
+		s.slaveOf = master.ID
+		s.keys = copyKeys(master.keys)
+	}
+	return c, nil
+}
+
+// NewEvenCluster starts masters owning evenly split slots and replicas of each master
+func NewEvenCluster(masters int, replicas int) (*Cluster, error) {
+	var specs []Spec
+	for i := 0; i < masters; i++ {
+		id := newID()
+		start := uint64(rcc.SlotCount * i / masters)
+		end := uint64(rcc.SlotCount*(i+1)/masters - 1)
+		specs = append(specs, Spec{ID: id, Slots: []rcc.Slot{{Start: start, End: end}}})
+		for j := 0; j < replicas; j++ {
+			specs = append(specs, Spec{SlaveOf: id})
+		}
+	}
+	return NewCluster(specs...)
+}
+
+// AddNode starts node joining fake cluster, node with Alone spec is met by CLUSTER MEET later
+func (c *Cluster) AddNode(spec Spec) (*Server, error) {
+	s, err := c.start(spec)
+	if err != nil {
+		return nil, err
+	}
+	if spec.Alone {
+		return s, nil
+	}
+
+	w := c.world
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var peer *Server
+	for _, p := range c.Servers {
+		if p != s && len(p.known) > 1 {
+			peer = p
+			break
+		}
+	}
+	if peer != nil {
+		w.meet(s, peer)
+	}
+	if spec.SlaveOf != "" {
+		master := w.server(spec.SlaveOf)
+		if master == nil {
+			return nil, fmt.Errorf("rcctest: master %s of %s is not found", spec.SlaveOf, s.ID)
+		}
+		s.master = false
+		s.slaveOf = master.ID
+		s.keys = copyKeys(master.keys)
+	}
+	return s, nil
+}
+
+func (c *Cluster) start(spec Spec) (*Server, error) {
+	id := spec.ID
+	if id == "" {
+		id = newID()
+	}
+	w := c.world
+	s, err := newServer(w, id)
+	if err != nil {
+		return nil, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.servers = append(w.servers, s)
+	c.Servers = append(c.Servers, s)
+
+	s.flags = spec.Flags
+	s.epoch = spec.Epoch
+	if s.epoch == 0 && spec.SlaveOf == "" && len(spec.Slots) > 0 {
+		s.epoch = w.maxEpoch() + 1
+	}
+	for _, slot := range spec.Slots {
+		for i := slot.Start; i <= slot.End; i++ {
+			if w.owner[i] != "" {
+				return nil, fmt.Errorf("rcctest: slot %d is owned by %s and %s", i, w.owner[i], id)
+			}
+			w.owner[i] = id
+		}
+	}
+	for k, v := range spec.Keys {
+		s.keys[k] = &entry{value: v}
+	}
+	return s, nil
+}
+
+// Addrs returns addresses of nodes
+func (c *Cluster) Addrs() (addrs []string) {
+	for _, s := range c.Servers {
+		addrs = append(addrs, s.Addr)
+	}
+	return addrs
+}
+
+// Server returns node of ID, or nil
+func (c *Cluster) Server(id string) *Server {
+	c.world.mu.Lock()
+	defer c.world.mu.Unlock()
+	return c.world.server(id)
+}
+
+// Owner returns node ID owning slot, or empty string
+func (c *Cluster) Owner(slot int) string {
+	c.world.mu.Lock()
+	defer c.world.mu.Unlock()
+	return c.world.owner[slot]
+}
+
+// Close stops every node
+func (c *Cluster) Close() {
+	for _, s := range c.Servers {
+		s.Close()
+	}
+}
+
+// meet makes every node known by s or peer know each other
+func (w *world) meet(s *Server, peer *Server) {
+	known := copyKnown(s.known)
+	for id := range peer.known {
+		known[id] = true
+	}
+	for id := range known {
+		if n := w.server(id); n != nil {
+			n.known = copyKnown(known)
+		}
+	}
+}
+
+// SlaveOf returns master node ID, or empty string for master
+func (s *Server) SlaveOf() string {
+	s.world.mu.Lock()
+	defer s.world.mu.Unlock()
+	return s.slaveOf
+}
+
+// Known returns sorted IDs of nodes known by s including itself
+func (s *Server) Known() (ids []string) {
+	s.world.mu.Lock()
+	defer s.world.mu.Unlock()
+	for id := range s.known {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Set sets key without routing check, as if key was written before test
+func (s *Server) Set(key string, value string) {
+	s.world.mu.Lock()
+	defer s.world.mu.Unlock()
+	s.keys[key] = &entry{value: value}
+}
+
+// SetFlags replaces extra flags such as "fail" seen by every node
+func (s *Server) SetFlags(flags ...string) {
+	s.world.mu.Lock()
+	defer s.world.mu.Unlock()
+	s.flags = flags
+}
+
+// SetReplication scripts replication state of slave, lag is bytes behind master offset
+func (s *Server) SetReplication(lag int64, lastIO int, linkDown bool) {
+	s.world.mu.Lock()
+	defer s.world.mu.Unlock()
+	s.replLag = lag
+	s.lastIO = lastIO
+	s.linkDown = linkDown
+}
+
+// SetClusterNodes scripts reply of CLUSTER NODES of s such as one without myself line, empty nodes restores topology
+func (s *Server) SetClusterNodes(nodes string) {
+	s.world.mu.Lock()
+	defer s.world.mu.Unlock()
+	s.nodes = nodes
+}
+
+func newID() string {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func copyKnown(known map[string]bool) map[string]bool {
+	m := make(map[string]bool, len(known))
+	for id := range known {
+		m[id] = true
+	}
+	return m
+}
+
+func copyKeys(keys map[string]*entry) map[string]*entry {
+	m := make(map[string]*entry, len(keys))
+	for k, e := range keys {
+		copied := *e
+		m[k] = &copied
+	}
+	return m
+}
@@ -0,0 +1,820 @@
+package rcctest
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kizkoh/rcc/rcc"
+)
+
+// do runs command of client connection and returns reply
+func (s *Server) do(c *conn, args []string) interface{} {
+	name := strings.ToLower(args[0])
+	switch name {
+	case "auth":
+		return s.auth(c, args[1:])
+	case "quit":
+		return status("OK")
+	}
+	if !c.authed {
+		return redisError("NOAUTH Authentication required.")
+	}
+
+	s.world.mu.Lock()
+	defer s.world.mu.Unlock()
+
+	asking := c.asking
+	c.asking = false
+	switch name {
+	case "ping":
+		if len(args) > 1 {
+			return args[1]
+		}
+		return status("PONG")
+	case "echo":
+		if len(args) != 2 {
+			return wrongArgs(name)
+		}
+		return args[1]
+	case "select":
+		if len(args) != 2 || args[1] != "0" {
+			return redisError("ERR SELECT is not allowed in cluster mode")
+		}
+		return status("OK")
+	case "readonly", "readwrite":
+		return status("OK")
+	case "asking":
+		c.asking = true
+		return status("OK")
+	case "command":
+		return []string{}
+	case "cluster":
+		if len(args) < 2 {
+			return wrongArgs(name)
+		}
+		return s.cluster(strings.ToLower(args[1]), args[2:])
+	case "info":
+		section := "default"
+		if len(args) > 1 {
+			section = strings.ToLower(args[1])
+		}
+		return s.info(section)
+	case "dbsize":
+		return len(s.liveKeys())
+	case "migrate":
+		return s.migrate(args[1:])
+	case "get", "set", "del", "exists", "ttl", "pttl", "type", "expire":
+		return s.key(name, args[1:], asking)
+	case "memory":
+		// SAMPLES is accepted and ignored as every value is string
+		if len(args) < 3 || strings.ToLower(args[1]) != "usage" {
+			return wrongArgs(name)
+		}
+		return s.key(name, args[2:3], asking)
+	}
+	return redisError(fmt.Sprintf("ERR unknown command '%s'", args[0]))
+}
+
+func (s *Server) auth(c *conn, args []string) interface{} {
+	var user, password string
+	switch len(args) {
+	case 1:
+		user, password = "default", args[0]
+	case 2:
+		user, password = args[0], args[1]
+	default:
+		return wrongArgs("auth")
+	}
+	if s.Password == "" {
+		return redisError("ERR AUTH <password> called without any password configured for the default user.")
+	}
+	expected := s.User
+	if expected == "" {
+		expected = "default"
+	}
+	if user != expected || password != s.Password {
+		return redisError("WRONGPASS invalid username-password pair or user is disabled.")
+	}
+	c.authed = true
+	return status("OK")
+}
+
+func wrongArgs(name string) redisError {
+	return redisError(fmt.Sprintf("ERR wrong number of arguments for '%s' command", name))
+}
+
+// cluster runs CLUSTER subcommand, caller holds world.mu
+func (s *Server) cluster(sub string, args []string) interface{} {
+	w := s.world
+	switch sub {
+	case "nodes":
+		if s.nodes != "" {
+			return s.nodes
+		}
+		return s.clusterNodes()
+	case "info":
+		return s.clusterInfo()
+	case "myid":
+		return s.ID
+	case "keyslot":
+		if len(args) != 1 {
+			return wrongArgs("cluster|keyslot")
+		}
+		return rcc.KeySlot(args[0])
+	case "meet":
+		if len(args) < 2 {
+			return wrongArgs("cluster|meet")
+		}
+		if net.ParseIP(args[0]) == nil {
+			return redisError(fmt.Sprintf("ERR Invalid node address specified: %s:%s", args[0], args[1]))
+		}
+		// unknown address is accepted as redis does, handshake never completes
+		if peer := w.serverByAddr(net.JoinHostPort(args[0], args[1])); peer != nil && !peer.closed {
+			w.meet(s, peer)
+		}
+		return status("OK")
+	case "replicate":
+		if len(args) != 1 {
+			return wrongArgs("cluster|replicate")
+		}
+		master := w.server(args[0])
+		if master == nil || !s.known[master.ID] {
+			return redisError(fmt.Sprintf("ERR Unknown node %s", args[0]))
+		}
+		if master == s {
+			return redisError("ERR Can't replicate myself")
+		}
+		if !master.master {
+			return redisError("ERR I can only replicate a master, not a replica.")
+		}
+		if s.master && (len(s.ownSlots()) > 0 || len(s.liveKeys()) > 0) {
+			return redisError("ERR To set a master the node must be empty and without assigned slots.")
+		}
+		s.master = false
+		s.slaveOf = master.ID
+		s.keys = copyKeys(master.keys)
+		return status("OK")
+	case "forget":
+		if len(args) != 1 {
+			return wrongArgs("cluster|forget")
+		}
+		switch {
+		case args[0] == s.ID:
+			return redisError("ERR I tried hard but I can't forget myself...")
+		case args[0] == s.slaveOf:
+			return redisError("ERR Can't forget my master!")
+		case !s.known[args[0]]:
+			return redisError(fmt.Sprintf("ERR Unknown node %s", args[0]))
+		}
+		delete(s.known, args[0])
+		return status("OK")
+	case "countkeysinslot":
+		if len(args) != 1 {
+			return wrongArgs("cluster|countkeysinslot")
+		}
+		slot, err := parseSlot(args[0])
+		if err != nil {
+			return err
+		}
+		return len(s.keysInSlot(slot))
+	case "getkeysinslot":
+		if len(args) != 2 {
+			return wrongArgs("cluster|getkeysinslot")
+		}
+		slot, err := parseSlot(args[0])
+		if err != nil {
+			return err
+		}
+		count, perr := strconv.Atoi(args[1])
+		if perr != nil || count < 0 {
+			return redisError("ERR Invalid number of keys")
+		}
+		keys := s.keysInSlot(slot)
+		if len(keys) > count {
+			keys = keys[:count]
+		}
+		return keys
+	case "addslots":
+		if len(args) == 0 {
+			return wrongArgs("cluster|addslots")
+		}
+		var slots []int
+		for _, arg := range args {
+			slot, err := parseSlot(arg)
+			if err != nil {
+				return err
+			}
+			if w.owner[slot] != "" {
+				return redisError(fmt.Sprintf("ERR Slot %d is already busy", slot))
+			}
+			slots = append(slots, slot)
+		}
+		for _, slot := range slots {
+			w.owner[slot] = s.ID
+		}
+		return status("OK")
+	case "setslot":
+		return s.setSlot(args)
+	case "reset":
+		if s.master && len(s.liveKeys()) > 0 {
+			return redisError("ERR CLUSTER RESET can't be called with master nodes containing keys")
+		}
+		for _, slot := range s.ownSlots() {
+			w.owner[slot] = ""
+		}
+		s.master = true
+		s.slaveOf = ""
+		s.known = map[string]bool{s.ID: true}
+		s.importing = make(map[int]string)
+		s.migrating = make(map[int]string)
+		if len(args) > 0 && strings.ToLower(args[0]) == "hard" {
+			s.epoch = 0
+		}
+		return status("OK")
+	}
+	return redisError(fmt.Sprintf("ERR Unknown subcommand '%s'", sub))
+}
+
+// setSlot runs CLUSTER SETSLOT, caller holds world.mu
+func (s *Server) setSlot(args []string) interface{} {
+	w := s.world
+	if len(args) < 2 {
+		return wrongArgs("cluster|setslot")
+	}
+	slot, err := parseSlot(args[0])
+	if err != nil {
+		return err
+	}
+	if !s.master {
+		return redisError("ERR Please use SETSLOT only with masters.")
+	}
+	action := strings.ToLower(args[1])
+	if action == "stable" {
+		delete(s.importing, slot)
+		delete(s.migrating, slot)
+		return status("OK")
+	}
+	if len(args) != 3 {
+		return wrongArgs("cluster|setslot")
+	}
+	node := w.server(args[2])
+	if node == nil || !s.known[node.ID] {
+		return redisError(fmt.Sprintf("ERR I don't know about node %s", args[2]))
+	}
+
+	switch action {
+	case "importing":
+		if w.owner[slot] == s.ID {
+			return redisError(fmt.Sprintf("ERR I'm already the owner of hash slot %d", slot))
+		}
+		s.importing[slot] = node.ID
+	case "migrating":
+		if w.owner[slot] != s.ID {
+			return redisError(fmt.Sprintf("ERR I'm not the owner of hash slot %d", slot))
+		}
+		s.migrating[slot] = node.ID
+	case "node":
+		if !node.master {
+			return redisError("ERR Target node is not a master")
+		}
+		if w.owner[slot] == s.ID && node != s && len(s.keysInSlot(slot)) > 0 {
+			return redisError(fmt.Sprintf("ERR Can't assign hashslot %d to a different node while I still hold keys for this hash slot.", slot))
+		}
+		w.owner[slot] = node.ID
+		if node != s {
+			delete(s.migrating, slot)
+		}
+		if _, ok := s.importing[slot]; ok && node == s {
+			// importing node bumps epoch to win ownership of slot
+			delete(s.importing, slot)
+			s.epoch = w.maxEpoch() + 1
+		}
+	default:
+		return redisError("ERR Invalid CLUSTER SETSLOT action or number of arguments.")
+	}
+	return status("OK")
+}
+
+func parseSlot(arg string) (int, interface{}) {
+	slot, err := strconv.Atoi(arg)
+	if err != nil || slot < 0 || slot >= rcc.SlotCount {
+		return 0, redisError("ERR Invalid or out of range slot")
+	}
+	return slot, nil
+}
+
+// clusterNodes renders CLUSTER NODES seen from s, caller holds world.mu
+func (s *Server) clusterNodes() string {
+	w := s.world
+	var b strings.Builder
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	for _, n := range w.servers {
+		if !s.known[n.ID] {
+			continue
+		}
+		var flags []string
+		if n == s {
+			flags = append(flags, "myself")
+		}
+		if n.master {
+			flags = append(flags, "master")
+		} else {
+			flags = append(flags, "slave")
+		}
+		flags = append(flags, n.flags...)
+
+		master := "-"
+		epoch := n.epoch
+		if !n.master {
+			master = n.slaveOf
+			if m := w.server(n.slaveOf); m != nil {
+				epoch = m.epoch
+			}
+		}
+		pong := now
+		if n == s {
+			pong = 0
+		}
+		link := "connected"
+		if n.closed {
+			link = "disconnected"
+		}
+		fmt.Fprintf(&b, "%s %s:%d@%d %s %s 0 %d %d %s", n.ID, n.Host, n.Port, n.Port+10000, strings.Join(flags, ","), master, pong, epoch, link)
+		if n.master {
+			for _, r := range slotRanges(n.ownSlots()) {
+				b.WriteString(" " + r)
+			}
+		}
+		if n == s {
+			for _, slot := range sortedSlots(s.migrating) {
+				fmt.Fprintf(&b, " [%d->-%s]", slot, s.migrating[slot])
+			}
+			for _, slot := range sortedSlots(s.importing) {
+				fmt.Fprintf(&b, " [%d-<-%s]", slot, s.importing[slot])
+			}
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// clusterInfo renders CLUSTER INFO seen from s, caller holds world.mu
+func (s *Server) clusterInfo() string {
+	w := s.world
+	assigned := 0
+	for _, owner := range w.owner {
+		if owner != "" && s.known[owner] {
+			assigned++
+		}
+	}
+	state := "ok"
+	if assigned < rcc.SlotCount {
+		state = "fail"
+	}
+	size := 0
+	var currentEpoch uint64
+	for _, n := range w.servers {
+		if !s.known[n.ID] {
+			continue
+		}
+		if n.master && len(n.ownSlots()) > 0 {
+			size++
+		}
+		if n.epoch > currentEpoch {
+			currentEpoch = n.epoch
+		}
+	}
+	myEpoch := s.epoch
+	if m := w.server(s.slaveOf); m != nil {
+		myEpoch = m.epoch
+	}
+
+	lines := []string{
+		"cluster_enabled:1",
+		"cluster_state:" + state,
+		fmt.Sprintf("cluster_slots_assigned:%d", assigned),
+		fmt.Sprintf("cluster_slots_ok:%d", assigned),
+		"cluster_slots_pfail:0",
+		"cluster_slots_fail:0",
+		fmt.Sprintf("cluster_known_nodes:%d", len(s.known)),
+		fmt.Sprintf("cluster_size:%d", size),
+		fmt.Sprintf("cluster_current_epoch:%d", currentEpoch),
+		fmt.Sprintf("cluster_my_epoch:%d", myEpoch),
+	}
+	return strings.Join(lines, "\r\n") + "\r\n"
+}
+
+// info renders INFO section, caller holds world.mu
+func (s *Server) info(section string) string {
+	sections := []string{"server", "clients", "memory", "replication", "cluster", "keyspace"}
+	switch section {
+	case "default", "all", "everything":
+	default:
+		sections = []string{section}
+	}
+
+	var b strings.Builder
+	for _, name := range sections {
+		lines := s.infoSection(name)
+		if lines == nil {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString("# " + strings.Title(name) + "\r\n")
+		for _, line := range lines {
+			b.WriteString(line + "\r\n")
+		}
+	}
+	return b.String()
+}
+
+func (s *Server) infoSection(name string) []string {
+	w := s.world
+	switch name {
+	case "server":
+		return []string{
+			"redis_version:6.2.0",
+			"redis_mode:cluster",
+			fmt.Sprintf("tcp_port:%d", s.Port),
+		}
+	case "clients":
+		return []string{fmt.Sprintf("connected_clients:%d", len(s.conns))}
+	case "memory":
+		used := s.usedMemory()
+		return []string{
+			fmt.Sprintf("used_memory:%d", used),
+			fmt.Sprintf("used_memory_human:%.2fK", float64(used)/1024),
+		}
+	case "replication":
+		if !s.master {
+			master := w.server(s.slaveOf)
+			link := "up"
+			if s.linkDown || master == nil || master.closed {
+				link = "down"
+			}
+			var offset int64
+			var host string
+			var port int
+			if master != nil {
+				offset = master.replOffset - s.replLag
+				host, port = master.Host, master.Port
+			}
+			return []string{
+				"role:slave",
+				"master_host:" + host,
+				fmt.Sprintf("master_port:%d", port),
+				"master_link_status:" + link,
+				fmt.Sprintf("master_last_io_seconds_ago:%d", s.lastIO),
+				"master_sync_in_progress:0",
+				fmt.Sprintf("slave_repl_offset:%d", offset),
+				"slave_priority:100",
+				"slave_read_only:1",
+				"connected_slaves:0",
+				fmt.Sprintf("master_repl_offset:%d", offset),
+			}
+		}
+		lines := []string{"role:master"}
+		var slaves []string
+		for _, n := range w.servers {
+			if n.slaveOf != s.ID || n.master || n.closed {
+				continue
+			}
+			state := "online"
+			if n.linkDown {
+				state = "wait_bgsave"
+			}
+			slaves = append(slaves, fmt.Sprintf("slave%d:ip=%s,port=%d,state=%s,offset=%d,lag=%d", len(slaves), n.Host, n.Port, state, s.replOffset-n.replLag, n.lastIO))
+		}
+		lines = append(lines, fmt.Sprintf("connected_slaves:%d", len(slaves)))
+		lines = append(lines, slaves...)
+		lines = append(lines, fmt.Sprintf("master_repl_offset:%d", s.replOffset))
+		return lines
+	case "cluster":
+		return []string{"cluster_enabled:1"}
+	case "keyspace":
+		keys := s.liveKeys()
+		if len(keys) == 0 {
+			return []string{}
+		}
+		expires := 0
+		for _, k := range keys {
+			if !s.keys[k].expireAt.IsZero() {
+				expires++
+			}
+		}
+		return []string{fmt.Sprintf("db0:keys=%d,expires=%d,avg_ttl=0", len(keys), expires)}
+	}
+	return nil
+}
+
+// key runs key command routed by slot of keys, caller holds world.mu
+func (s *Server) key(name string, args []string, asking bool) interface{} {
+	if len(args) == 0 {
+		return wrongArgs(name)
+	}
+	keys := args[:1]
+	if name == "del" || name == "exists" {
+		keys = args
+	}
+	if redirect := s.route(keys, asking); redirect != nil {
+		return redirect
+	}
+
+	switch name {
+	case "get":
+		if len(args) != 1 {
+			return wrongArgs(name)
+		}
+		if e := s.lookup(args[0]); e != nil {
+			return e.value
+		}
+		return nil
+	case "memory":
+		if e := s.lookup(args[0]); e != nil {
+			return entrySize(args[0], e)
+		}
+		return nil
+	case "set":
+		if len(args) != 2 && len(args) != 4 {
+			return wrongArgs(name)
+		}
+		e := &entry{value: args[1]}
+		if len(args) == 4 {
+			n, err := strconv.ParseInt(args[3], 10, 64)
+			if err != nil || n <= 0 {
+				return redisError("ERR invalid expire time in 'set' command")
+			}
+			switch strings.ToLower(args[2]) {
+			case "ex":
+				e.expireAt = time.Now().Add(time.Duration(n) * time.Second)
+			case "px":
+				e.expireAt = time.Now().Add(time.Duration(n) * time.Millisecond)
+			default:
+				return redisError("ERR syntax error")
+			}
+		}
+		s.keys[args[0]] = e
+		s.written(args)
+		return status("OK")
+	case "del", "exists":
+		n := 0
+		for _, k := range args {
+			if s.lookup(k) != nil {
+				n++
+				if name == "del" {
+					delete(s.keys, k)
+				}
+			}
+		}
+		if name == "del" && n > 0 {
+			s.written(args)
+		}
+		return n
+	case "ttl", "pttl":
+		if len(args) != 1 {
+			return wrongArgs(name)
+		}
+		e := s.lookup(args[0])
+		switch {
+		case e == nil:
+			return -2
+		case e.expireAt.IsZero():
+			return -1
+		case name == "ttl":
+			return int64(time.Until(e.expireAt) / time.Second)
+		default:
+			return int64(time.Until(e.expireAt) / time.Millisecond)
+		}
+	case "type":
+		if len(args) != 1 {
+			return wrongArgs(name)
+		}
+		if s.lookup(args[0]) == nil {
+			return status("none")
+		}
+		return status("string")
+	case "expire":
+		if len(args) != 2 {
+			return wrongArgs(name)
+		}
+		n, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return redisError("ERR value is not an integer or out of range")
+		}
+		e := s.lookup(args[0])
+		if e == nil {
+			return 0
+		}
+		e.expireAt = time.Now().Add(time.Duration(n) * time.Second)
+		s.written(args)
+		return 1
+	}
+	return redisError(fmt.Sprintf("ERR unknown command '%s'", name))
+}
+
+// migrate runs MIGRATE moving keys into node at host:port, caller holds world.mu
+func (s *Server) migrate(args []string) interface{} {
+	if len(args) < 5 {
+		return wrongArgs("migrate")
+	}
+	target := s.world.serverByAddr(net.JoinHostPort(args[0], args[1]))
+	if target == nil || target.closed {
+		return redisError("IOERR error or timeout connecting to the client")
+	}
+	keys := []string{args[2]}
+	var copying, replace bool
+	var user, password string
+	for i := 5; i < len(args); i++ {
+		switch strings.ToLower(args[i]) {
+		case "copy":
+			copying = true
+		case "replace":
+			replace = true
+		case "auth":
+			if i+1 >= len(args) {
+				return redisError("ERR syntax error")
+			}
+			user, password = "default", args[i+1]
+			i++
+		case "auth2":
+			if i+2 >= len(args) {
+				return redisError("ERR syntax error")
+			}
+			user, password = args[i+1], args[i+2]
+			i += 2
+		case "keys":
+			if args[2] != "" {
+				return redisError("ERR When using MIGRATE KEYS option, the key argument must be set to the empty string")
+			}
+			keys = args[i+1:]
+			i = len(args)
+		default:
+			return redisError("ERR syntax error")
+		}
+	}
+
+	if target.Password != "" {
+		expected := target.User
+		if expected == "" {
+			expected = "default"
+		}
+		switch {
+		case password == "":
+			return redisError("ERR Target instance replied with error: NOAUTH Authentication required.")
+		case user != expected || password != target.Password:
+			return redisError("ERR Target instance replied with error: WRONGPASS invalid username-password pair or user is disabled.")
+		}
+	}
+
+	moved := 0
+	for _, k := range keys {
+		e := s.lookup(k)
+		if e == nil {
+			continue
+		}
+		if target.lookup(k) != nil && !replace {
+			return redisError("BUSYKEY Target key name already exists.")
+		}
+		copied := *e
+		target.keys[k] = &copied
+		if !copying {
+			delete(s.keys, k)
+		}
+		moved++
+	}
+	if moved == 0 {
+		return status("NOKEY")
+	}
+	s.written(append([]string{"migrate"}, keys...))
+	target.written(append([]string{"restore"}, keys...))
+	return status("OK")
+}
+
+// route returns MOVED, ASK or CLUSTERDOWN error when keys are not served by s, caller holds world.mu
+func (s *Server) route(keys []string, asking bool) interface{} {
+	w := s.world
+	slot := rcc.KeySlot(keys[0])
+	for _, k := range keys[1:] {
+		if rcc.KeySlot(k) != slot {
+			return redisError("CROSSSLOT Keys in request don't hash to the same slot")
+		}
+	}
+	owner := w.owner[slot]
+	switch {
+	case owner == s.ID && s.master:
+		if target, ok := s.migrating[slot]; ok {
+			for _, k := range keys {
+				if s.lookup(k) == nil {
+					if n := w.server(target); n != nil {
+						return redisError(fmt.Sprintf("ASK %d %s", slot, n.Addr))
+					}
+				}
+			}
+		}
+		return nil
+	case asking && s.importing[slot] != "":
+		return nil
+	case owner == "" || !s.known[owner]:
+		return redisError("CLUSTERDOWN Hash slot not served")
+	}
+	return redisError(fmt.Sprintf("MOVED %d %s", slot, w.server(owner).Addr))
+}
+
+// lookup returns live entry of key, expired key is deleted
+func (s *Server) lookup(key string) *entry {
+	e, ok := s.keys[key]
+	if !ok {
+		return nil
+	}
+	if !e.expireAt.IsZero() && time.Now().After(e.expireAt) {
+		delete(s.keys, key)
+		return nil
+	}
+	return e
+}
+
+// written advances replication offset of master and copies keys to its slaves
+func (s *Server) written(args []string) {
+	for _, arg := range args {
+		s.replOffset += int64(len(arg))
+	}
+	for _, n := range s.world.servers {
+		if !n.master && n.slaveOf == s.ID {
+			n.keys = copyKeys(s.keys)
+		}
+	}
+}
+
+func (s *Server) liveKeys() (keys []string) {
+	for k := range s.keys {
+		if s.lookup(k) != nil {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *Server) keysInSlot(slot int) (keys []string) {
+	for k := range s.keys {
+		if rcc.KeySlot(k) == slot && s.lookup(k) != nil {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *Server) usedMemory() int {
+	used := 1 << 20
+	for k, e := range s.keys {
+		used += entrySize(k, e)
+	}
+	return used
+}
+
+// entrySize returns bytes of key reported by MEMORY USAGE
+func entrySize(key string, e *entry) int {
+	return len(key) + len(e.value) + 48
+}
+
+// ownSlots returns sorted slots owned by s, caller holds world.mu
+func (s *Server) ownSlots() (slots []int) {
+	for slot, owner := range s.world.owner {
+		if owner == s.ID {
+			slots = append(slots, slot)
+		}
+	}
+	return slots
+}
+
+// slotRanges renders sorted slots as CLUSTER NODES ranges
+func slotRanges(slots []int) (ranges []string) {
+	for i := 0; i < len(slots); {
+		j := i
+		for j+1 < len(slots) && slots[j+1] == slots[j]+1 {
+			j++
+		}
+		if i == j {
+			ranges = append(ranges, strconv.Itoa(slots[i]))
+		} else {
+			ranges = append(ranges, fmt.Sprintf("%d-%d", slots[i], slots[j]))
+		}
+		i = j + 1
+	}
+	return ranges
+}
+
+func sortedSlots(m map[int]string) (slots []int) {
+	for slot := range m {
+		slots = append(slots, slot)
+	}
+	sort.Ints(slots)
+	return slots
+}
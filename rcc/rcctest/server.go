@@ -0,0 +1,231 @@
+package rcctest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// status is RESP simple string reply
+type status string
+
+// redisError is RESP error reply
+type redisError string
+
+// entry is value of key
+type entry struct {
+	value    string
+	expireAt time.Time
+}
+
+// Server is fake redis cluster node listening on localhost
+//
+// User and Password must be set before clients connect, AUTH is required when Password is set.
+type Server struct {
+	ID       string
+	Addr     string
+	Host     string
+	Port     int
+	User     string
+	Password string
+
+	world    *world
+	listener net.Listener
+	wg       sync.WaitGroup
+
+	// fields below are guarded by world.mu
+	master     bool
+	slaveOf    string
+	flags      []string
+	epoch      uint64
+	known      map[string]bool
+	importing  map[int]string
+	migrating  map[int]string
+	keys       map[string]*entry
+	replOffset int64
+	replLag    int64
+	lastIO     int
+	linkDown   bool
+	nodes      string // scripted reply of CLUSTER NODES
+	conns      map[net.Conn]bool
+	closed     bool
+}
+
+// conn is client connection state
+type conn struct {
+	authed bool
+	asking bool
+}
+
+func newServer(w *world, id string) (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	addr := listener.Addr().(*net.TCPAddr)
+	s := &Server{
+		ID:        id,
+		Addr:      addr.String(),
+		Host:      addr.IP.String(),
+		Port:      addr.Port,
+		world:     w,
+		listener:  listener,
+		master:    true,
+		known:     map[string]bool{id: true},
+		importing: make(map[int]string),
+		migrating: make(map[int]string),
+		keys:      make(map[string]*entry),
+		conns:     make(map[net.Conn]bool),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Close stops server and closes client connections
+func (s *Server) Close() {
+	s.world.mu.Lock()
+	if s.closed {
+		s.world.mu.Unlock()
+		return
+	}
+	s.closed = true
+	s.listener.Close()
+	for c := range s.conns {
+		c.Close()
+	}
+	s.world.mu.Unlock()
+	s.wg.Wait()
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		nc, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.world.mu.Lock()
+		if s.closed {
+			s.world.mu.Unlock()
+			nc.Close()
+			return
+		}
+		s.conns[nc] = true
+		s.world.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(nc)
+			s.world.mu.Lock()
+			delete(s.conns, nc)
+			s.world.mu.Unlock()
+			nc.Close()
+		}()
+	}
+}
+
+func (s *Server) handle(nc net.Conn) {
+	r := bufio.NewReader(nc)
+	w := bufio.NewWriter(nc)
+	c := &conn{authed: s.Password == ""}
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		reply := s.do(c, args)
+		writeReply(w, reply)
+		// replies of pipelined commands are flushed together
+		if r.Buffered() == 0 {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+		if strings.ToLower(args[0]) == "quit" {
+			w.Flush()
+			return
+		}
+	}
+}
+
+// readCommand reads RESP array of bulk strings or inline command
+func readCommand(r *bufio.Reader) (args []string, err error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return strings.Fields(line), nil
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < n; i++ {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(line, "$") {
+			return nil, fmt.Errorf("bulk string is expected: %q", line)
+		}
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args = append(args, string(buf[:size]))
+	}
+	return args, nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// writeReply writes reply as RESP, string is written as bulk string and nil as null bulk string
+func writeReply(w *bufio.Writer, reply interface{}) {
+	switch v := reply.(type) {
+	case nil:
+		w.WriteString("$-1\r\n")
+	case status:
+		fmt.Fprintf(w, "+%s\r\n", v)
+	case redisError:
+		fmt.Fprintf(w, "-%s\r\n", v)
+	case int:
+		fmt.Fprintf(w, ":%d\r\n", v)
+	case int64:
+		fmt.Fprintf(w, ":%d\r\n", v)
+	case string:
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(v), v)
+	case []string:
+		fmt.Fprintf(w, "*%d\r\n", len(v))
+		for _, item := range v {
+			writeReply(w, item)
+		}
+	case []interface{}:
+		fmt.Fprintf(w, "*%d\r\n", len(v))
+		for _, item := range v {
+			writeReply(w, item)
+		}
+	default:
+		panic(fmt.Sprintf("rcctest: unknown reply %T", reply))
+	}
+}
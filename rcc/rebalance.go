@@ -0,0 +1,172 @@
+package rcc
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// DefaultRebalanceThreshold is deviation from mean weight within which master is left as is
+var DefaultRebalanceThreshold = 0.02
+
+// RebalancePlan returns plan moving slots between healthy masters of cluster so that weight of every master is even
+//
+// weights is weight of every slot such as keys or bytes, and masters within threshold of mean weight are left as is.
+// open is open slots seen by every master as OpenSlots returns, and plan is refused while any slot is open.
+// Each move takes range of consecutive slots of a master over mean and gives it to a master under mean, and the range
+// weighs no more than the smaller of what one is over and the other under mean, by floorRange. Of every such pair of
+// masters the range of most weight is taken, so that weight moved in total is no more than masters are over mean,
+// which is the least any plan evening them moves, such as bytes copied by MIGRATE. Moving ranges rather than single
+// slots keeps slot ownership in few ranges, and hot slot heavier than what masters are off mean stays.
+// Slot moved once is not moved again.
+func RebalancePlan(cluster []ClusterNode, open map[int][]Slot, weights []int64, threshold float64, seed string, command string) (*Plan, error) {
+	for slot := 0; slot < SlotCount; slot++ {
+		if _, ok := open[slot]; ok {
+			return nil, wrapError(ErrSlotOpen, fmt.Sprintf("slot %d, fix it before rebalance", slot))
+		}
+	}
+	if len(weights) != SlotCount {
+		return nil, newError(fmt.Sprintf("Weights of %d slots are given for %d slots", len(weights), SlotCount))
+	}
+	if threshold <= 0 {
+		threshold = DefaultRebalanceThreshold
+	}
+	plan := NewPlan(command, seed)
+
+	type master struct {
+		node  ClusterNode
+		load  int64
+		slots []int
+	}
+	var masters []*master
+	byID := make(map[string]*master)
+	for _, node := range cluster {
+		if node.Slave {
+			continue
+		}
+		for _, slot := range node.Slots {
+			if slot.From != "" || slot.To != "" {
+				return nil, wrapError(ErrSlotOpen, fmt.Sprintf("slot %d of %s, fix it before rebalance", slot.Start, node.Addr()))
+			}
+		}
+		if node.HasFlag("fail") || node.HasFlag("pfail") || node.HasFlag("noaddr") {
+			if len(node.Slots) > 0 {
+				return nil, wrapError(ErrMasterFailing, fmt.Sprintf("%s, fail it over before rebalance", node.Addr()))
+			}
+			continue
+		}
+		m := &master{node: node}
+		masters = append(masters, m)
+		byID[node.ID] = m
+		epoch := node.ConfigEpoch
+		plan.Require(Precondition{Type: PreconditionNode, ID: node.ID, Role: "master", ConfigEpoch: &epoch})
+	}
+	if len(masters) < 2 {
+		return plan, nil
+	}
+
+	var total int64
+	for slot, id := range SlotOwners(cluster) {
+		m, ok := byID[id]
+		if !ok {
+			continue
+		}
+		if weights[slot] < 0 {
+			return nil, wrapError(ErrUnknownWeight, fmt.Sprintf("slot %d, rebalance needs weight of every slot", slot))
+		}
+		m.load += weights[slot]
+		m.slots = append(m.slots, slot)
+		total += weights[slot]
+	}
+	mean := total / int64(len(masters))
+	margin := int64(float64(mean) * threshold)
+
+	// owner of every moved slot keyed by slot
+	moved := make(map[int]*master)
+	to := make(map[int]*master)
+	// every move reduces weight masters are over mean, so that moves end
+	for {
+		var donor, receiver *master
+		var start, end int
+		var w int64
+		for _, d := range masters {
+			excess := d.load - mean
+			if excess <= margin {
+				continue
+			}
+			var slotKeys []SlotKeys
+			for _, slot := range d.slots {
+				if _, ok := moved[slot]; !ok {
+					slotKeys = append(slotKeys, SlotKeys{Slot: slot, Keys: weights[slot]})
+				}
+			}
+			for _, r := range masters {
+				deficit := mean - r.load
+				if deficit <= margin {
+					continue
+				}
+				limit := excess
+				if deficit < limit {
+					limit = deficit
+				}
+				if s, e, x := floorRange(slotKeys, limit); x > w {
+					donor, receiver, start, end, w = d, r, s, e, x
+				}
+			}
+		}
+		if donor == nil {
+			break
+		}
+		for slot := start; slot <= end; slot++ {
+			moved[slot], to[slot] = donor, receiver
+		}
+		donor.load -= w
+		receiver.load += w
+	}
+
+	// consecutive slots between same masters are moved in one step
+	owned := make(map[string][]string)
+	for slot := 0; slot < SlotCount; {
+		from, ok := moved[slot]
+		if !ok {
+			slot++
+			continue
+		}
+		end := slot
+		for end+1 < SlotCount && moved[end+1] == from && to[end+1] == to[slot] {
+			end++
+		}
+		owned[from.node.ID] = append(owned[from.node.ID], FormatSlotRange(slot, end))
+		plan.Add(Step{
+			Type:  StepMigrateSlot,
+			Slots: FormatSlotRange(slot, end),
+			From:  &NodeRef{ID: from.node.ID, Addr: from.node.Addr()},
+			To:    &NodeRef{ID: to[slot].node.ID, Addr: to[slot].node.Addr()},
+		})
+		slot = end + 1
+	}
+	ids := make([]string, 0, len(owned))
+	for id := range owned {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		plan.Require(Precondition{Type: PreconditionOwner, ID: id, Slots: owned[id]})
+	}
+	return plan, nil
+}
+
+// MemoryWeights returns bytes of every slot estimated by EstimateMemory from keys of slot in counts
+//
+// Slot of unknown bytes is -1.
+func MemoryWeights(ctx context.Context, manager *Manager, cluster []ClusterNode, counts []int64, opt SampleOptions) ([]int64, error) {
+	slots, err := EstimateMemory(ctx, manager, cluster, counts, opt)
+	if err != nil {
+		return nil, err
+	}
+	weights := make([]int64, SlotCount)
+	for i, slot := range slots {
+		weights[i] = slot.Bytes
+	}
+	return weights, nil
+}
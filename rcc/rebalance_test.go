@@ -0,0 +1,263 @@
+package rcc
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// testMaster returns healthy master owning slot ranges
+func testMaster(id string, port uint64, slots ...Slot) ClusterNode {
+	return ClusterNode{ID: id, IP: "127.0.0.1", Port: port, Flags: []string{"master"}, Master: true, Slots: slots}
+}
+
+// evenWeights returns weight w of every slot
+func evenWeights(w int64) []int64 {
+	weights := make([]int64, SlotCount)
+	for i := range weights {
+		weights[i] = w
+	}
+	return weights
+}
+
+// planMoves returns "FROM->TO:SLOTS" of migrate steps of plan
+func planMoves(plan *Plan) []string {
+	var moves []string
+	for _, step := range plan.Steps {
+		moves = append(moves, fmt.Sprintf("%s->%s:%s", step.From.ID, step.To.ID, step.Slots))
+	}
+	return moves
+}
+
+func TestRebalancePlan(t *testing.T) {
+	hot := make([]int64, SlotCount)
+	hot[100] = 1000000
+
+	failing := testMaster("c", 7002, Slot{Start: 16000, End: 16383})
+	failing.Flags = []string{"master", "fail"}
+	open := testMaster("a", 7000, Slot{Start: 0, End: 8191}, Slot{Start: 10, End: 10, To: "b"})
+	unknown := evenWeights(1)
+	unknown[100] = -1
+
+	tests := []struct {
+		name    string
+		cluster []ClusterNode
+		open    map[int][]Slot
+		weights []int64
+		want    []string
+		wantErr error
+	}{
+		{
+			name: "contiguous range is moved in one step",
+			cluster: []ClusterNode{
+				testMaster("a", 7000, Slot{Start: 0, End: 12287}),
+				testMaster("b", 7001, Slot{Start: 12288, End: 16383}),
+			},
+			weights: evenWeights(1),
+			want:    []string{"a->b:0-4095"},
+		},
+		{
+			name: "every master is filled by range of its own",
+			cluster: []ClusterNode{
+				testMaster("a", 7000, Slot{Start: 0, End: 16383}),
+				testMaster("b", 7001),
+				testMaster("c", 7002),
+			},
+			weights: evenWeights(1),
+			want:    []string{"a->b:0-5460", "a->c:5461-10921"},
+		},
+		{
+			name: "balanced within threshold",
+			cluster: []ClusterNode{
+				testMaster("a", 7000, Slot{Start: 0, End: 8250}),
+				testMaster("b", 7001, Slot{Start: 8251, End: 16383}),
+			},
+			weights: evenWeights(1),
+		},
+		{
+			name: "hot slot heavier than difference stays",
+			cluster: []ClusterNode{
+				testMaster("a", 7000, Slot{Start: 0, End: 8191}),
+				testMaster("b", 7001, Slot{Start: 8192, End: 16383}),
+			},
+			weights: hot,
+		},
+		{
+			name: "single master",
+			cluster: []ClusterNode{
+				testMaster("a", 7000, Slot{Start: 0, End: 16383}),
+			},
+			weights: evenWeights(1),
+		},
+		{
+			name: "failing master owning slots",
+			cluster: []ClusterNode{
+				testMaster("a", 7000, Slot{Start: 0, End: 8191}),
+				testMaster("b", 7001, Slot{Start: 8192, End: 15999}),
+				failing,
+			},
+			weights: evenWeights(1),
+			wantErr: ErrMasterFailing,
+		},
+		{
+			name: "open slot",
+			cluster: []ClusterNode{
+				open,
+				testMaster("b", 7001, Slot{Start: 8192, End: 16383}),
+			},
+			weights: evenWeights(1),
+			wantErr: ErrSlotOpen,
+		},
+		{
+			// CLUSTER NODES of seed does not show slot open on other master
+			name: "open slot seen by other master",
+			cluster: []ClusterNode{
+				testMaster("a", 7000, Slot{Start: 0, End: 8191}),
+				testMaster("b", 7001, Slot{Start: 8192, End: 16383}),
+			},
+			open:    map[int][]Slot{9000: {{Start: 9000, End: 9000, From: "a"}}},
+			weights: evenWeights(1),
+			wantErr: ErrSlotOpen,
+		},
+		{
+			name: "unknown weight",
+			cluster: []ClusterNode{
+				testMaster("a", 7000, Slot{Start: 0, End: 8191}),
+				testMaster("b", 7001, Slot{Start: 8192, End: 16383}),
+			},
+			weights: unknown,
+			wantErr: ErrUnknownWeight,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := RebalancePlan(tt.cluster, tt.open, tt.weights, 0, "127.0.0.1:7000", "rcc rebalance")
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("RebalancePlan() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RebalancePlan() error = %v", err)
+			}
+			got := planMoves(plan)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("RebalancePlan() moves = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRebalancePlanFragmentation(t *testing.T) {
+	// weights vary slot by slot, moves still take few ranges
+	weights := make([]int64, SlotCount)
+	for i := range weights {
+		weights[i] = int64(i%7 + i%13)
+	}
+	cluster := []ClusterNode{
+		testMaster("a", 7000, Slot{Start: 0, End: 9999}),
+		testMaster("b", 7001, Slot{Start: 10000, End: 13999}),
+		testMaster("c", 7002, Slot{Start: 14000, End: 16383}),
+	}
+	plan, err := RebalancePlan(cluster, nil, weights, 0.01, "127.0.0.1:7000", "rcc rebalance")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Steps) == 0 || len(plan.Steps) > 4 {
+		t.Errorf("RebalancePlan() moves %v, want 1 to 4 ranges", planMoves(plan))
+	}
+
+	loads := make(map[string]int64)
+	var total int64
+	moved := make(map[int]string)
+	for _, step := range plan.Steps {
+		start, end, err := ParseSlotRange(step.Slots)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for slot := start; slot <= end; slot++ {
+			moved[slot] = step.To.ID
+		}
+	}
+	for slot, id := range SlotOwners(cluster) {
+		if to, ok := moved[slot]; ok {
+			id = to
+		}
+		loads[id] += weights[slot]
+		total += weights[slot]
+	}
+	mean := total / 3
+	if got, want := movedWeight(t, plan, weights), excessWeight(cluster, weights); got > want {
+		t.Errorf("RebalancePlan() moves weight %d, want no more than %d masters are over mean", got, want)
+	}
+	for id, load := range loads {
+		if load < mean*98/100 || load > mean*102/100 {
+			t.Errorf("master %s weighs %d after plan, want within 2%% of %d", id, load, mean)
+		}
+	}
+}
+
+// movedWeight returns weight of slots moved by plan
+func movedWeight(t *testing.T, plan *Plan, weights []int64) (moved int64) {
+	t.Helper()
+	for _, step := range plan.Steps {
+		start, end, err := ParseSlotRange(step.Slots)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for slot := start; slot <= end; slot++ {
+			moved += weights[slot]
+		}
+	}
+	return moved
+}
+
+// excessWeight returns weight masters of cluster are over mean in total, the least moved to even them
+func excessWeight(cluster []ClusterNode, weights []int64) (excess int64) {
+	loads := make(map[string]int64)
+	var total int64
+	for slot, id := range SlotOwners(cluster) {
+		loads[id] += weights[slot]
+		total += weights[slot]
+	}
+	mean := total / int64(len(loads))
+	for _, load := range loads {
+		if load > mean {
+			excess += load - mean
+		}
+	}
+	return excess
+}
+
+func TestRebalancePlanMovesLeast(t *testing.T) {
+	// a of 600 owns slots of 60, b of 598 and c of 296 own slots of 1, mean is 498.
+	// Range of a closest to its excess of 102 is 120, which takes a under mean to be refilled by 18 from b.
+	// Plan moves 100 from b and 60 from a instead, and leaves a 42 over mean as no slot of a fits in it.
+	weights := make([]int64, SlotCount)
+	for slot := 0; slot < 10; slot++ {
+		weights[slot] = 60
+	}
+	for slot := 10; slot < 608; slot++ {
+		weights[slot] = 1
+	}
+	for slot := 8192; slot < 8488; slot++ {
+		weights[slot] = 1
+	}
+	cluster := []ClusterNode{
+		testMaster("a", 7000, Slot{Start: 0, End: 9}),
+		testMaster("b", 7001, Slot{Start: 10, End: 8191}),
+		testMaster("c", 7002, Slot{Start: 8192, End: 16383}),
+	}
+	plan, err := RebalancePlan(cluster, nil, weights, 0, "127.0.0.1:7000", "rcc rebalance")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := planMoves(plan), []string{"a->c:0", "b->c:10-109"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("RebalancePlan() moves = %v, want %v", got, want)
+	}
+	if got, want := movedWeight(t, plan, weights), excessWeight(cluster, weights); got != 160 || got > want {
+		t.Errorf("RebalancePlan() moves weight %d, want 160 of %d masters are over mean", got, want)
+	}
+}
@@ -0,0 +1,446 @@
+package rcc
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// DesiredState is cluster shape reconciled by 'rcc reconcile'
+//
+//	replicas: 1
+//	spread: zone
+//	masters:
+//	  - {addr: 10.0.0.1:6379, slots: ["0-8191"], labels: {zone: a}}
+//	  - {addr: 10.0.0.2:6379, weight: 1, labels: {zone: b}}
+//	nodes:
+//	  - {addr: 10.0.0.3:6379, labels: {zone: b}}
+//	  - {addr: 10.0.0.4:6379, labels: {zone: a}}
+//
+// Masters owning no explicit slots share remaining slots by weight, nodes are replicas assigned to masters.
+// Live nodes not listed are forgotten after their slots are moved.
+type DesiredState struct {
+	Replicas int             `yaml:"replicas"` // replicas per master
+	Spread   string          `yaml:"spread"`   // label key in which replica must differ from its master, such as zone
+	Masters  []DesiredMaster `yaml:"masters"`
+	Nodes    []DesiredNode   `yaml:"nodes"`
+}
+
+// DesiredMaster is master in desired state
+type DesiredMaster struct {
+	Addr   string            `yaml:"addr"`
+	Slots  []string          `yaml:"slots"`  // slot ranges such as "0-8191"
+	Weight float64           `yaml:"weight"` // share of slots not in explicit ranges, 1 when zero
+	Labels map[string]string `yaml:"labels"`
+}
+
+// DesiredNode is node available as replica in desired state
+type DesiredNode struct {
+	Addr   string            `yaml:"addr"`
+	Labels map[string]string `yaml:"labels"`
+}
+
+// LoadDesiredState read desired state file
+func LoadDesiredState(path string) (desired DesiredState, err error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+		return desired, err
+	}
+	if err := yaml.UnmarshalStrict(b, &desired); err != nil {
+		err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: %s", App.Name, App.Version, path))
+		return desired, err
+	}
+	if len(desired.Masters) == 0 {
+		err = errors.New(fmt.Sprintf("No master is desired in %s", path))
+		err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+		return desired, err
+	}
+	return desired, nil
+}
+
+// member is node listed in desired state
+type member struct {
+	node   ClusterNode
+	master *DesiredMaster
+	labels map[string]string
+	joined bool // node joins cluster by plan
+}
+
+// ReconcilePlan returns plan converging cluster seen from node at seed into desired state
+//
+// Plan meets new nodes, assigns and moves slots, replicates masters and forgets nodes not listed.
+// New master waits until it and every healthy master know each other before slots are migrated into it.
+// Desired master which is slave now, and slot to be moved from failing master are errors, fail it over first.
+// Seed must be listed in desired state, seed to be forgotten is an error.
+func ReconcilePlan(ctx context.Context, manager *Manager, desired DesiredState, seed string, command string) (*Plan, error) {
+	cluster, err := ClusterNodesContext(ctx, manager.Client(seed))
+	if err != nil {
+		return nil, err
+	}
+	live := make(map[string]ClusterNode)
+	byID := make(map[string]ClusterNode)
+	var myself ClusterNode
+	for i, node := range cluster {
+		if node.HasFlag("myself") {
+			if node.IP == "" {
+				// node not meeting other nodes yet does not know its own IP
+				node.IP, _, _ = net.SplitHostPort(seed)
+				cluster[i] = node
+			}
+			myself = node
+		}
+		for _, slot := range node.Slots {
+			if slot.From != "" || slot.To != "" {
+				return nil, wrapError(ErrSlotOpen, fmt.Sprintf("slot %d of %s, fix it before reconcile", slot.Start, node.Addr()))
+			}
+		}
+		live[node.Addr()] = node
+		byID[node.ID] = node
+	}
+
+	plan := NewPlan(command, seed)
+
+	// desired nodes, new nodes meet seed
+	var members []*member
+	seen := make(map[string]bool)
+	add := func(addr string, master *DesiredMaster, labels map[string]string) error {
+		addr, err := ResolveNodeContext(ctx, manager, addr)
+		if err != nil {
+			return err
+		}
+		if seen[addr] {
+			return newError(fmt.Sprintf("Node %s is listed twice", addr))
+		}
+		seen[addr] = true
+		m := &member{master: master, labels: labels}
+		if node, ok := live[addr]; ok {
+			m.node = node
+		} else {
+			client := manager.Client(addr)
+			id, err := nodeID(ctx, client)
+			if err != nil {
+				return err
+			}
+			if err := AssertEmptyNodeContext(ctx, client); err != nil {
+				return err
+			}
+			host, port, _ := net.SplitHostPort(addr)
+			p, _ := strconv.ParseUint(port, 10, 64)
+			m.node = ClusterNode{ID: id, IP: host, Host: host, Port: p, Master: true}
+			m.joined = true
+			plan.Require(Precondition{Type: PreconditionEmpty, Addr: addr})
+			plan.AddCommand(addr, "cluster", "meet", myself.IP, strconv.FormatUint(myself.Port, 10))
+			plan.Add(Step{Type: StepWaitJoin, Node: seed, ID: id})
+		}
+		members = append(members, m)
+		return nil
+	}
+	for i := range desired.Masters {
+		if err := add(desired.Masters[i].Addr, &desired.Masters[i], desired.Masters[i].Labels); err != nil {
+			return nil, err
+		}
+	}
+	for _, n := range desired.Nodes {
+		if err := add(n.Addr, nil, n.Labels); err != nil {
+			return nil, err
+		}
+	}
+	listed := make(map[string]*member)
+	for _, m := range members {
+		listed[m.node.ID] = m
+	}
+	// seed not listed is forgotten and reset, steps after it would run against node out of cluster
+	if _, ok := listed[myself.ID]; !ok {
+		return nil, newError(fmt.Sprintf("Seed %s is not desired, plan from node staying in cluster", seed))
+	}
+
+	// live nodes plan relies on keep role and epoch, failing nodes are only forgotten
+	for _, node := range cluster {
+		if node.HasFlag("fail") || node.HasFlag("pfail") {
+			continue
+		}
+		c := Precondition{Type: PreconditionNode, ID: node.ID, Role: nodeRole(node)}
+		if node.Slave {
+			c.SlaveOf = node.SlaveOf
+		} else {
+			epoch := node.ConfigEpoch
+			c.ConfigEpoch = &epoch
+		}
+		plan.Require(c)
+	}
+
+	var masters []*member
+	for _, m := range members {
+		if m.master == nil {
+			continue
+		}
+		if m.node.Slave {
+			return nil, newError(fmt.Sprintf("Desired master %s is slave of %s, fail it over before reconcile", m.node.Addr(), m.node.SlaveOf))
+		}
+		masters = append(masters, m)
+	}
+
+	if err := reconcileSlots(plan, cluster, masters, byID); err != nil {
+		return nil, err
+	}
+	if err := reconcileReplicas(plan, desired, members, masters); err != nil {
+		return nil, err
+	}
+
+	// nodes not listed are forgotten by every listed node, and reset not to rejoin by gossip
+	for _, node := range cluster {
+		if _, ok := listed[node.ID]; ok {
+			continue
+		}
+		for _, m := range members {
+			plan.AddCommand(m.node.Addr(), "cluster", "forget", node.ID)
+		}
+		if !node.HasFlag("fail") && !node.HasFlag("noaddr") {
+			plan.AddCommand(node.Addr(), "cluster", "reset", "soft")
+		}
+	}
+	return plan, nil
+}
+
+// reconcileSlots adds steps moving slots into desired masters, unassigned slots are added to them
+func reconcileSlots(plan *Plan, cluster []ClusterNode, masters []*member, byID map[string]ClusterNode) error {
+	var owner, target [SlotCount]string
+	for _, node := range cluster {
+		for _, slot := range node.Slots {
+			for i := slot.Start; i <= slot.End && i < SlotCount; i++ {
+				owner[i] = node.ID
+			}
+		}
+	}
+
+	// explicit slot ranges
+	var weighted []*member
+	for _, m := range masters {
+		if len(m.master.Slots) == 0 {
+			weighted = append(weighted, m)
+			continue
+		}
+		for _, r := range m.master.Slots {
+			start, end, err := ParseSlotRange(r)
+			if err != nil {
+				return err
+			}
+			for i := start; i <= end; i++ {
+				if target[i] != "" {
+					return newError(fmt.Sprintf("Slot %d is desired in several masters", i))
+				}
+				target[i] = m.node.ID
+			}
+		}
+	}
+
+	// remaining slots are shared by weight, slots stay in current owner within its quota
+	var rest []int
+	for i := range target {
+		if target[i] == "" {
+			rest = append(rest, i)
+		}
+	}
+	if len(rest) > 0 {
+		if len(weighted) == 0 {
+			return newError(fmt.Sprintf("%d slots such as slot %d are not desired in any master", len(rest), rest[0]))
+		}
+		quota := weightedQuota(len(rest), weighted)
+		count := make(map[string]int)
+		for _, i := range rest {
+			if q, ok := quota[owner[i]]; ok && count[owner[i]] < q {
+				target[i] = owner[i]
+				count[owner[i]]++
+			}
+		}
+		for _, i := range rest {
+			if target[i] != "" {
+				continue
+			}
+			for _, m := range weighted {
+				if count[m.node.ID] < quota[m.node.ID] {
+					target[i] = m.node.ID
+					count[m.node.ID]++
+					break
+				}
+			}
+		}
+	}
+
+	// consecutive slots moving between same nodes are one step
+	nodes := make(map[string]ClusterNode)
+	for id, node := range byID {
+		nodes[id] = node
+	}
+	joined := make(map[string]bool)
+	for _, m := range masters {
+		nodes[m.node.ID] = m.node
+		joined[m.node.ID] = m.joined
+	}
+	type move struct {
+		start, end int
+		from, to   string
+	}
+	var moves []move
+	owned := make(map[string][]string)
+	unassigned := make(map[string][]string)
+	var order []string
+	for start := 0; start < SlotCount; {
+		end := start
+		for end+1 < SlotCount && owner[end+1] == owner[start] && target[end+1] == target[start] {
+			end++
+		}
+		from, to := owner[start], target[start]
+		switch {
+		case from == to:
+		case from == "":
+			if _, ok := unassigned[to]; !ok {
+				order = append(order, to)
+			}
+			for i := start; i <= end; i++ {
+				unassigned[to] = append(unassigned[to], strconv.Itoa(i))
+			}
+		default:
+			// keys of failing master can not be migrated, fail it over or recover it first
+			if node := nodes[from]; node.HasFlag("fail") || node.HasFlag("pfail") || node.HasFlag("noaddr") {
+				return wrapError(ErrMasterFailing, fmt.Sprintf("slot %d of %s, fail it over before reconcile", start, node.Addr()))
+			}
+			owned[from] = append(owned[from], FormatSlotRange(start, end))
+			moves = append(moves, move{start: start, end: end, from: from, to: to})
+		}
+		start = end + 1
+	}
+
+	// new master importing slots knows every peer of migration and is known by every master SETSLOT NODE is sent to,
+	// gossip from seed alone may not have reached them yet
+	waited := make(map[[2]string]bool)
+	waitJoin := func(addr string, id string) {
+		if addr == plan.Cluster || waited[[2]string{addr, id}] {
+			return
+		}
+		waited[[2]string{addr, id}] = true
+		plan.Add(Step{Type: StepWaitJoin, Node: addr, ID: id})
+	}
+	for _, mv := range moves {
+		if !joined[mv.to] {
+			continue
+		}
+		waitJoin(nodes[mv.to].Addr(), mv.from)
+		for _, node := range cluster {
+			if node.Slave || node.HasFlag("fail") || node.HasFlag("pfail") || node.HasFlag("noaddr") {
+				continue
+			}
+			waitJoin(node.Addr(), mv.to)
+		}
+	}
+	for _, mv := range moves {
+		plan.Add(Step{
+			Type:  StepMigrateSlot,
+			Slots: FormatSlotRange(mv.start, mv.end),
+			From:  &NodeRef{ID: mv.from, Addr: nodes[mv.from].Addr()},
+			To:    &NodeRef{ID: mv.to, Addr: nodes[mv.to].Addr()},
+		})
+	}
+	for _, id := range order {
+		plan.AddCommand(nodes[id].Addr(), append([]string{"cluster", "addslots"}, unassigned[id]...)...)
+	}
+	var ids []string
+	for id := range owned {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		plan.Require(Precondition{Type: PreconditionOwner, ID: id, Slots: owned[id]})
+	}
+	return nil
+}
+
+// weightedQuota returns number of slots keyed by master ID, remainder goes to masters in order
+func weightedQuota(total int, masters []*member) map[string]int {
+	var sum float64
+	for _, m := range masters {
+		sum += masterWeight(m)
+	}
+	quota := make(map[string]int)
+	assigned := 0
+	for _, m := range masters {
+		q := int(math.Floor(float64(total) * masterWeight(m) / sum))
+		quota[m.node.ID] = q
+		assigned += q
+	}
+	for i := 0; assigned < total; i++ {
+		quota[masters[i%len(masters)].node.ID]++
+		assigned++
+	}
+	return quota
+}
+
+func masterWeight(m *member) float64 {
+	if m.master.Weight <= 0 {
+		return 1
+	}
+	return m.master.Weight
+}
+
+// reconcileReplicas adds steps replicating desired masters, replica differs from its master in spread label
+func reconcileReplicas(plan *Plan, desired DesiredState, members []*member, masters []*member) error {
+	spreadOK := func(replica *member, master *member) bool {
+		return desired.Spread == "" || replica.labels[desired.Spread] != master.labels[desired.Spread]
+	}
+
+	// current replicas are kept up to desired count
+	replicas := make(map[string]int)
+	byMaster := make(map[string]*member)
+	for _, m := range masters {
+		byMaster[m.node.ID] = m
+	}
+	var spare []*member
+	for _, m := range members {
+		if m.master != nil {
+			continue
+		}
+		if master, ok := byMaster[m.node.SlaveOf]; ok && m.node.Slave && replicas[master.node.ID] < desired.Replicas && spreadOK(m, master) {
+			replicas[master.node.ID]++
+			continue
+		}
+		spare = append(spare, m)
+	}
+
+	for _, master := range masters {
+		for replicas[master.node.ID] < desired.Replicas {
+			i := 0
+			for i < len(spare) && !spreadOK(spare[i], master) {
+				i++
+			}
+			if i == len(spare) {
+				return newError(fmt.Sprintf("No node is left for replica %d of %s", replicas[master.node.ID]+1, master.node.Addr()))
+			}
+			replica := spare[i]
+			spare = append(spare[:i], spare[i+1:]...)
+			if replica.joined {
+				plan.Add(Step{Type: StepWaitJoin, Node: replica.node.Addr(), ID: master.node.ID})
+			}
+			plan.AddCommand(replica.node.Addr(), "cluster", "replicate", master.node.ID)
+			replicas[master.node.ID]++
+		}
+	}
+
+	for _, m := range spare {
+		if m.node.Slave {
+			if _, ok := byMaster[m.node.SlaveOf]; !ok {
+				return newError(fmt.Sprintf("Node %s is left as slave of %s not desired", m.node.Addr(), m.node.SlaveOf))
+			}
+			continue
+		}
+		DefaultLogger.Warn("node is left as master without slots", "node", m.node.Addr())
+	}
+	return nil
+}
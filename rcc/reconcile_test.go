@@ -0,0 +1,143 @@
+package rcc_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/kizkoh/rcc/rcc"
+	"github.com/kizkoh/rcc/rcc/rcctest"
+)
+
+func TestReconcilePlan(t *testing.T) {
+	ids := map[string]string{
+		"A": strings.Repeat("a", 40),
+		"B": strings.Repeat("b", 40),
+		"C": strings.Repeat("c", 40),
+		"N": strings.Repeat("e", 40),
+	}
+	tests := []struct {
+		name    string
+		specs   []rcctest.Spec
+		open    bool // slot 10 of A is migrating to B
+		masters map[string][]string
+		want    []string
+		wantErr error
+		wantMsg string
+	}{
+		{
+			name: "desired state is current state",
+			specs: []rcctest.Spec{
+				{ID: ids["A"], Slots: []rcc.Slot{{Start: 0, End: 8191}}},
+				{ID: ids["B"], Slots: []rcc.Slot{{Start: 8192, End: 16383}}},
+			},
+			masters: map[string][]string{"A": {"0-8191"}, "B": {"8192-16383"}},
+		},
+		{
+			name: "new master and every peer know each other before migration",
+			specs: []rcctest.Spec{
+				{ID: ids["A"], Slots: []rcc.Slot{{Start: 0, End: 8191}}},
+				{ID: ids["B"], Slots: []rcc.Slot{{Start: 8192, End: 12287}}},
+				{ID: ids["C"], Slots: []rcc.Slot{{Start: 12288, End: 16383}}},
+				{ID: ids["N"], Alone: true},
+			},
+			masters: map[string][]string{"A": {"0-8191"}, "B": {"8192-10239"}, "C": {"12288-16383"}, "N": {"10240-12287"}},
+			want: []string{
+				"N: cluster meet 127.0.0.1 A",
+				"A: wait until N joins",
+				"N: wait until B joins",
+				"B: wait until N joins",
+				"C: wait until N joins",
+				"migrate slots 10240-12287 from B to N",
+			},
+		},
+		{
+			name: "slots of failing master",
+			specs: []rcctest.Spec{
+				{ID: ids["A"], Slots: []rcc.Slot{{Start: 0, End: 8191}}},
+				{ID: ids["B"], Slots: []rcc.Slot{{Start: 8192, End: 16383}}, Flags: []string{"fail"}},
+			},
+			masters: map[string][]string{"A": {"0-16383"}},
+			wantErr: rcc.ErrMasterFailing,
+		},
+		{
+			name: "seed is forgotten",
+			specs: []rcctest.Spec{
+				{ID: ids["A"], Slots: []rcc.Slot{{Start: 0, End: 8191}}},
+				{ID: ids["B"], Slots: []rcc.Slot{{Start: 8192, End: 16383}}},
+			},
+			masters: map[string][]string{"B": {"0-16383"}},
+			wantMsg: "Seed",
+		},
+		{
+			name: "open slot",
+			specs: []rcctest.Spec{
+				{ID: ids["A"], Slots: []rcc.Slot{{Start: 0, End: 8191}}},
+				{ID: ids["B"], Slots: []rcc.Slot{{Start: 8192, End: 16383}}},
+			},
+			open:    true,
+			masters: map[string][]string{"A": {"0-8191"}, "B": {"8192-16383"}},
+			wantErr: rcc.ErrSlotOpen,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster, err := rcctest.NewCluster(tt.specs...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer cluster.Close()
+			manager := rcc.NewManager(rcc.DefaultClientOptions)
+			defer manager.Close()
+
+			// steps are compared by names of nodes, and port of seed in CLUSTER MEET
+			var pairs []string
+			for name, id := range ids {
+				if s := cluster.Server(id); s != nil {
+					pairs = append(pairs, s.Addr, name, id, name, fmt.Sprintf(" %d", s.Port), " "+name)
+				}
+			}
+			names := strings.NewReplacer(pairs...)
+
+			seed := cluster.Server(ids["A"]).Addr
+			if tt.open {
+				if _, err := manager.Client(seed).(rcc.Commander).Do(context.Background(), "cluster", "setslot", "10", "migrating", ids["B"]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var desired rcc.DesiredState
+			for _, name := range []string{"A", "B", "C", "N"} {
+				if slots, ok := tt.masters[name]; ok {
+					desired.Masters = append(desired.Masters, rcc.DesiredMaster{Addr: cluster.Server(ids[name]).Addr, Slots: slots})
+				}
+			}
+
+			plan, err := rcc.ReconcilePlan(context.Background(), manager, desired, seed, "rcc reconcile")
+			if tt.wantMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantMsg) {
+					t.Fatalf("ReconcilePlan() error = %v, want error containing %q", err, tt.wantMsg)
+				}
+				return
+			}
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ReconcilePlan() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReconcilePlan() error = %v", err)
+			}
+			var got []string
+			for _, step := range plan.Steps {
+				got = append(got, names.Replace(step.String()))
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("ReconcilePlan() steps =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
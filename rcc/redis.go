@@ -0,0 +1,278 @@
+package rcc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// Client is redis node commands used by rcc, NewRedisClient adapts go-redis client into Client
+//
+// Every command is bounded by ctx, fake Client lets topology logic be tested without redis.
+type Client interface {
+	ClusterNodes(ctx context.Context) (string, error)
+	ClusterInfo(ctx context.Context) (string, error)
+	ClusterMeet(ctx context.Context, host string, port string) error
+	ClusterReplicate(ctx context.Context, nodeID string) error
+	ClusterCountKeysInSlot(ctx context.Context, slot int) (int64, error)
+	ClusterCountKeysInSlots(ctx context.Context, slots []int) ([]int64, error)
+	ClusterGetKeysInSlot(ctx context.Context, slot int, count int) ([]string, error)
+	MemoryUsage(ctx context.Context, keys []string) ([]int64, error)
+	TypeAndPTTL(ctx context.Context, keys []string) ([]string, []int64, error)
+	Info(ctx context.Context, section string) (string, error)
+	ClusterSetSlot(ctx context.Context, slot int, subcommand string, nodeID string) error
+	Migrate(ctx context.Context, host string, port string, keys []string, timeout time.Duration) error
+	Close() error
+}
+
+// Commander is Client running any command such as command step of Plan, client of NewClient and NewRedisClient is Commander
+type Commander interface {
+	Do(ctx context.Context, args ...interface{}) (interface{}, error)
+}
+
+// redisClient is Client of go-redis client
+type redisClient struct {
+	client   *redis.Client
+	user     string // credentials sent to target node of MIGRATE
+	password string
+
+	mu      sync.Mutex
+	migrate map[time.Duration]*redis.Client // clients of MIGRATE keyed by its timeout
+}
+
+// NewRedisClient returns Client sending commands with go-redis client, MIGRATE authenticates with password of client
+//
+// go-redis v6 options have no ACL user, client authenticates as user with password of client by 'AUTH user password'
+// on every new connection when user is given. It must be called before client connects.
+func NewRedisClient(client *redis.Client, user string) Client {
+	opt := client.Options()
+	password := opt.Password
+	if user != "" {
+		opt.Password = ""
+		opt.OnConnect = authUser(user, password, opt.OnConnect)
+	}
+	return &redisClient{client: client, user: user, password: password}
+}
+
+// authUser returns OnConnect authenticating connection as ACL user before onConnect
+func authUser(user string, password string, onConnect func(conn *redis.Conn) error) func(conn *redis.Conn) error {
+	return func(conn *redis.Conn) error {
+		if err := conn.Do("auth", user, password).Err(); err != nil {
+			return err
+		}
+		if onConnect != nil {
+			return onConnect(conn)
+		}
+		return nil
+	}
+}
+
+func (c *redisClient) ClusterNodes(ctx context.Context) (string, error) {
+	val, err := wait(ctx, func() (interface{}, error) {
+		return c.client.ClusterNodes().Result()
+	})
+	s, _ := val.(string)
+	return s, err
+}
+
+func (c *redisClient) ClusterInfo(ctx context.Context) (string, error) {
+	val, err := wait(ctx, func() (interface{}, error) {
+		return c.client.ClusterInfo().Result()
+	})
+	s, _ := val.(string)
+	return s, err
+}
+
+func (c *redisClient) ClusterMeet(ctx context.Context, host string, port string) error {
+	_, err := wait(ctx, func() (interface{}, error) {
+		return nil, c.client.ClusterMeet(host, port).Err()
+	})
+	return err
+}
+
+func (c *redisClient) ClusterReplicate(ctx context.Context, nodeID string) error {
+	_, err := wait(ctx, func() (interface{}, error) {
+		return nil, c.client.ClusterReplicate(nodeID).Err()
+	})
+	return err
+}
+
+func (c *redisClient) ClusterCountKeysInSlot(ctx context.Context, slot int) (int64, error) {
+	val, err := wait(ctx, func() (interface{}, error) {
+		return c.client.ClusterCountKeysInSlot(slot).Result()
+	})
+	n, _ := val.(int64)
+	return n, err
+}
+
+// ClusterCountKeysInSlots sends COUNTKEYSINSLOT of slots in one pipeline
+func (c *redisClient) ClusterCountKeysInSlots(ctx context.Context, slots []int) ([]int64, error) {
+	val, err := wait(ctx, func() (interface{}, error) {
+		pipe := c.client.Pipeline()
+		defer pipe.Close()
+		cmds := make([]*redis.IntCmd, len(slots))
+		for i, slot := range slots {
+			cmds[i] = pipe.ClusterCountKeysInSlot(slot)
+		}
+		if _, err := pipe.Exec(); err != nil {
+			return nil, err
+		}
+		counts := make([]int64, len(slots))
+		for i, cmd := range cmds {
+			counts[i] = cmd.Val()
+		}
+		return counts, nil
+	})
+	counts, _ := val.([]int64)
+	return counts, err
+}
+
+func (c *redisClient) ClusterGetKeysInSlot(ctx context.Context, slot int, count int) ([]string, error) {
+	val, err := wait(ctx, func() (interface{}, error) {
+		return c.client.ClusterGetKeysInSlot(slot, count).Result()
+	})
+	keys, _ := val.([]string)
+	return keys, err
+}
+
+// MemoryUsage sends MEMORY USAGE of keys in one pipeline, bytes of key not found is -1
+func (c *redisClient) MemoryUsage(ctx context.Context, keys []string) ([]int64, error) {
+	val, err := wait(ctx, func() (interface{}, error) {
+		pipe := c.client.Pipeline()
+		defer pipe.Close()
+		cmds := make([]*redis.IntCmd, len(keys))
+		for i, key := range keys {
+			cmds[i] = pipe.MemoryUsage(key)
+		}
+		if _, err := pipe.Exec(); err != nil && err != redis.Nil {
+			return nil, err
+		}
+		bytes := make([]int64, len(keys))
+		for i, cmd := range cmds {
+			switch err := cmd.Err(); {
+			case err == redis.Nil:
+				bytes[i] = -1
+			case err != nil:
+				return nil, err
+			default:
+				bytes[i] = cmd.Val()
+			}
+		}
+		return bytes, nil
+	})
+	bytes, _ := val.([]int64)
+	return bytes, err
+}
+
+// typeAndPTTL is reply of TypeAndPTTL
+type typeAndPTTL struct {
+	types []string
+	pttls []int64
+}
+
+// TypeAndPTTL sends TYPE and PTTL of keys in one pipeline, PTTL is -1 for key without expire and -2 for key not found
+func (c *redisClient) TypeAndPTTL(ctx context.Context, keys []string) ([]string, []int64, error) {
+	val, err := wait(ctx, func() (interface{}, error) {
+		pipe := c.client.Pipeline()
+		defer pipe.Close()
+		typeCmds := make([]*redis.StatusCmd, len(keys))
+		pttlCmds := make([]*redis.DurationCmd, len(keys))
+		for i, key := range keys {
+			typeCmds[i] = pipe.Type(key)
+			pttlCmds[i] = pipe.PTTL(key)
+		}
+		if _, err := pipe.Exec(); err != nil {
+			return nil, err
+		}
+		reply := typeAndPTTL{types: make([]string, len(keys)), pttls: make([]int64, len(keys))}
+		for i := range keys {
+			reply.types[i] = typeCmds[i].Val()
+			reply.pttls[i] = int64(pttlCmds[i].Val() / time.Millisecond)
+		}
+		return reply, nil
+	})
+	reply, _ := val.(typeAndPTTL)
+	return reply.types, reply.pttls, err
+}
+
+func (c *redisClient) Info(ctx context.Context, section string) (string, error) {
+	val, err := wait(ctx, func() (interface{}, error) {
+		return c.client.Info(section).Result()
+	})
+	s, _ := val.(string)
+	return s, err
+}
+
+// Migrate sends MIGRATE of keys into node at host:port, authenticated with credentials of c
+//
+// MIGRATE replies after target node stores keys, so that reply is read in timeout plus read timeout of c.
+func (c *redisClient) Migrate(ctx context.Context, host string, port string, keys []string, timeout time.Duration) error {
+	args := []interface{}{"migrate", host, port, "", 0, int64(timeout / time.Millisecond)}
+	switch {
+	case c.user != "":
+		args = append(args, "auth2", c.user, c.password)
+	case c.password != "":
+		args = append(args, "auth", c.password)
+	}
+	args = append(args, "keys")
+	for _, key := range keys {
+		args = append(args, key)
+	}
+	client := c.migrateClient(timeout)
+	_, err := wait(ctx, func() (interface{}, error) {
+		return nil, client.Do(args...).Err()
+	})
+	return err
+}
+
+func (c *redisClient) ClusterSetSlot(ctx context.Context, slot int, subcommand string, nodeID string) error {
+	_, err := wait(ctx, func() (interface{}, error) {
+		return nil, c.client.Do("cluster", "setslot", slot, subcommand, nodeID).Err()
+	})
+	return err
+}
+
+// migrateClient returns client of which read timeout is longer than timeout of MIGRATE
+//
+// go-redis v6 has no read timeout per command, client of same options but read timeout is kept for each timeout.
+func (c *redisClient) migrateClient(timeout time.Duration) *redis.Client {
+	opt := c.client.Options()
+	if opt.ReadTimeout < 0 {
+		return c.client
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	client, ok := c.migrate[timeout]
+	if !ok {
+		options := *opt
+		options.ReadTimeout = timeout + opt.ReadTimeout
+		client = redis.NewClient(&options)
+		traceCommands(client, opt.Addr)
+		if c.migrate == nil {
+			c.migrate = make(map[time.Duration]*redis.Client)
+		}
+		c.migrate[timeout] = client
+	}
+	return client
+}
+
+func (c *redisClient) Do(ctx context.Context, args ...interface{}) (interface{}, error) {
+	return wait(ctx, func() (interface{}, error) {
+		return c.client.Do(args...).Result()
+	})
+}
+
+func (c *redisClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	err := c.client.Close()
+	for timeout, client := range c.migrate {
+		if cerr := client.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		delete(c.migrate, timeout)
+	}
+	return err
+}
@@ -0,0 +1,112 @@
+package rcc
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// slowServer replies to every command with bulk string after delay
+func slowServer(t *testing.T, delay time.Duration, reply string) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve(t, l, delay, reply)
+	return l.Addr().String()
+}
+
+// serve replies to every command of connections accepted by l with bulk string after delay
+func serve(t *testing.T, l net.Listener, delay time.Duration, reply string) {
+	serveFunc(t, l, func() (string, bool) {
+		time.Sleep(delay)
+		return reply, true
+	})
+}
+
+// serveFunc replies to every command of connections accepted by l with bulk string returned by handle,
+// connection is closed without reply when handle returns false
+func serveFunc(t *testing.T, l net.Listener, handle func() (reply string, ok bool)) {
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			nc, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer nc.Close()
+				r := bufio.NewReader(nc)
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					// skip arguments of multi bulk request
+					if line[0] != '*' {
+						continue
+					}
+					n, err := strconv.Atoi(line[1 : len(line)-2])
+					if err != nil {
+						return
+					}
+					for i := 0; i < 2*n; i++ {
+						if _, err := r.ReadString('\n'); err != nil {
+							return
+						}
+					}
+					reply, ok := handle()
+					if !ok {
+						return
+					}
+					if _, err := nc.Write([]byte("$" + strconv.Itoa(len(reply)) + "\r\n" + reply + "\r\n")); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+}
+
+func TestWaitCanceled(t *testing.T) {
+	addr := slowServer(t, 100*time.Millisecond, "nodes")
+	client := NewRedisClient(redis.NewClient(&redis.Options{Addr: addr}), "")
+	defer client.Close()
+
+	for i := 0; i < 10; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		resp, err := client.ClusterNodes(ctx)
+		cancel()
+		if err != context.DeadlineExceeded {
+			t.Fatalf("ClusterNodes() error = %v, want %v", err, context.DeadlineExceeded)
+		}
+		if resp != "" {
+			t.Fatalf("ClusterNodes() = %q, want empty", resp)
+		}
+	}
+	// let abandoned commands return while test is running under -race
+	time.Sleep(200 * time.Millisecond)
+
+	resp, err := client.ClusterNodes(context.Background())
+	if err != nil || resp != "nodes" {
+		t.Fatalf("ClusterNodes() = %q, %v, want %q", resp, err, "nodes")
+	}
+}
+
+func TestMigrateClientReadTimeout(t *testing.T) {
+	client := NewClient("127.0.0.1:0", DefaultClientOptions).(*redisClient)
+	defer client.Close()
+	got := client.migrateClient(MigrateTimeout).Options().ReadTimeout
+	if want := MigrateTimeout + DefaultClientOptions.ReadTimeout; got != want {
+		t.Errorf("read timeout of MIGRATE = %v, want %v", got, want)
+	}
+	if client.migrateClient(MigrateTimeout) != client.migrateClient(MigrateTimeout) {
+		t.Error("client of MIGRATE is not reused")
+	}
+}
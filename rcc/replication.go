@@ -0,0 +1,303 @@
+package rcc
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Replication is replication state of node in 'INFO replication'
+type Replication struct {
+	Role             string
+	MasterReplOffset int64
+	// fields of slave
+	SlaveReplOffset      int64
+	MasterLinkStatus     string
+	MasterLastIOSeconds  int64 // -1 when link is down
+	MasterSyncInProgress bool
+	// slaves connected to master
+	Slaves []ReplicaState
+}
+
+// ReplicaState is 'slaveN' field of master in 'INFO replication'
+type ReplicaState struct {
+	IP     string
+	Port   int
+	State  string
+	Offset int64
+	Lag    int64 // seconds since last REPLCONF ACK of slave, not how far slave is behind
+}
+
+// ParseReplication parse 'INFO replication' result
+func ParseReplication(resp string) (repl Replication, err error) {
+	fields := ParseInfo(resp)
+	repl.Role = fields["role"]
+	repl.MasterLinkStatus = fields["master_link_status"]
+	repl.MasterSyncInProgress = fields["master_sync_in_progress"] == "1"
+	repl.MasterLastIOSeconds = -1
+	for key, v := range map[string]*int64{
+		"master_repl_offset":         &repl.MasterReplOffset,
+		"slave_repl_offset":          &repl.SlaveReplOffset,
+		"master_last_io_seconds_ago": &repl.MasterLastIOSeconds,
+	} {
+		value, ok := fields[key]
+		if !ok {
+			continue
+		}
+		if *v, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return repl, errors.WithStack(&ParseError{Line: key + ":" + value, Err: err})
+		}
+	}
+	for i := 0; ; i++ {
+		value, ok := fields[fmt.Sprintf("slave%d", i)]
+		if !ok {
+			break
+		}
+		slave, err := parseReplicaState(value)
+		if err != nil {
+			return repl, err
+		}
+		repl.Slaves = append(repl.Slaves, slave)
+	}
+	return repl, nil
+}
+
+// parseReplicaState parse 'ip=127.0.0.1,port=6380,state=online,offset=100,lag=0'
+func parseReplicaState(value string) (slave ReplicaState, err error) {
+	for _, kv := range strings.Split(value, ",") {
+		record := strings.SplitN(kv, "=", 2)
+		if len(record) < 2 {
+			continue
+		}
+		switch record[0] {
+		case "ip":
+			slave.IP = record[1]
+		case "state":
+			slave.State = record[1]
+		case "port":
+			slave.Port, err = strconv.Atoi(record[1])
+		case "offset":
+			slave.Offset, err = strconv.ParseInt(record[1], 10, 64)
+		case "lag":
+			slave.Lag, err = strconv.ParseInt(record[1], 10, 64)
+		}
+		if err != nil {
+			return slave, errors.WithStack(&ParseError{Line: value, Err: err})
+		}
+	}
+	return slave, nil
+}
+
+// LagOptions is limits of replica safe to fail over to
+type LagOptions struct {
+	MaxBytes   int64         // replication offset behind master
+	MaxSeconds int64         // seconds behind master, since last ack of slave or since last I/O from master
+	Interval   time.Duration // time between two reads of master offset timing lag, DefaultLagOptions.Interval when zero
+}
+
+// DefaultLagOptions is default limits of ReplicationReport
+var DefaultLagOptions = LagOptions{
+	MaxBytes:   1 << 20,
+	MaxSeconds: 10,
+	Interval:   time.Second,
+}
+
+// MasterReplication is replication offset of master and health of its slaves
+type MasterReplication struct {
+	Master   NodeRef      `json:"master"`
+	Offset   int64        `json:"master_repl_offset"` // -1 when 'INFO replication' of master can not be read
+	Rate     float64      `json:"write_rate"`         // bytes of offset advanced per second over interval, -1 when unknown
+	Error    string       `json:"error,omitempty"`    // why offset of master is unknown
+	Replicas []ReplicaLag `json:"replicas"`
+}
+
+// ReplicaLag is replication health of slave
+type ReplicaLag struct {
+	Master         NodeRef `json:"master"`
+	Replica        NodeRef `json:"replica"`
+	MasterOffset   int64   `json:"master_offset"`
+	Offset         int64   `json:"offset"`
+	LagBytes       int64   `json:"lag_bytes"`
+	LagSeconds     float64 `json:"lag_seconds"`      // time master took to write LagBytes at its rate, -1 when unknown
+	LastAckSeconds int64   `json:"last_ack_seconds"` // 'lag' of slave seen by master, -1 when master does not list slave
+	LinkStatus     string  `json:"link_status"`
+	LastIOSeconds  int64   `json:"last_io_seconds"` // -1 when link is down
+	Syncing        bool    `json:"syncing"`
+	Safe           bool    `json:"safe"`             // slave is close enough to master to fail over to
+	Reason         string  `json:"reason,omitempty"` // why slave is not safe
+}
+
+// ReplicationReport returns offset of every master and replication health of its slaves in cluster
+// from 'INFO replication' of every node
+//
+// Master listed as 'slaveN' reports seconds since last REPLCONF ACK of slave as 'lag', which is not how far slave is
+// behind and is reported as LastAckSeconds. Offset of master is read again after opt.Interval and timestamps of both
+// reads give its write rate, so that LagSeconds is LagBytes over the rate. It is unknown while master writes nothing,
+// and slave behind master idle over interval is judged by LagBytes alone. Slave whose INFO can not be read is
+// reported unsafe with reason, and error is returned only for interrupted ctx.
+func ReplicationReport(ctx context.Context, manager *Manager, cluster []ClusterNode, opt LagOptions) ([]MasterReplication, error) {
+	if opt.Interval <= 0 {
+		opt.Interval = DefaultLagOptions.Interval
+	}
+	infos := make(map[string]Replication)
+	at := make(map[string]time.Time)
+	errs := make(map[string]error)
+	for _, node := range cluster {
+		if node.HasFlag("noaddr") || (node.Slave && node.SlaveOf == "") {
+			continue
+		}
+		repl, err := readReplication(ctx, manager.NodeClient(node))
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			errs[node.ID] = err
+			continue
+		}
+		infos[node.ID], at[node.ID] = repl, time.Now()
+	}
+
+	// write rate of master from offsets read interval apart
+	rates := make(map[string]float64)
+	var start time.Time
+	for _, t := range at {
+		if start.IsZero() || t.Before(start) {
+			start = t
+		}
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(opt.Interval - time.Since(start)):
+	}
+	for _, node := range cluster {
+		first, ok := infos[node.ID]
+		if !ok || node.Slave {
+			continue
+		}
+		repl, err := readReplication(ctx, manager.NodeClient(node))
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			continue
+		}
+		rates[node.ID] = float64(repl.MasterReplOffset-first.MasterReplOffset) / time.Since(at[node.ID]).Seconds()
+	}
+
+	report := []MasterReplication{}
+	byID := make(map[string]int)
+	for _, node := range cluster {
+		if node.Slave || node.HasFlag("noaddr") {
+			continue
+		}
+		master := MasterReplication{Master: NodeRef{ID: node.ID, Addr: node.Addr()}, Offset: -1, Rate: -1, Replicas: []ReplicaLag{}}
+		if rate, ok := rates[node.ID]; ok {
+			master.Rate = rate
+		}
+		if info, ok := infos[node.ID]; ok {
+			master.Offset = info.MasterReplOffset
+		} else if err := errs[node.ID]; err != nil {
+			master.Error = err.Error()
+		}
+		byID[node.ID] = len(report)
+		report = append(report, master)
+	}
+	for _, node := range cluster {
+		if !node.Slave || node.SlaveOf == "" {
+			continue
+		}
+		i, ok := byID[node.SlaveOf]
+		if !ok {
+			// master not listed or without address is reported by slave
+			byID[node.SlaveOf] = len(report)
+			i = len(report)
+			report = append(report, MasterReplication{Master: NodeRef{ID: node.SlaveOf}, Offset: -1, Rate: -1, Replicas: []ReplicaLag{}})
+		}
+		lag := ReplicaLag{
+			Master:         report[i].Master,
+			Replica:        NodeRef{ID: node.ID, Addr: node.Addr()},
+			LagSeconds:     -1,
+			LastAckSeconds: -1,
+			LastIOSeconds:  -1,
+		}
+		minfo, mok := infos[node.SlaveOf]
+		sinfo, sok := infos[node.ID]
+		if mok {
+			lag.MasterOffset = minfo.MasterReplOffset
+			if slave, ok := findReplicaState(minfo.Slaves, node); ok {
+				lag.Offset, lag.LastAckSeconds = slave.Offset, slave.Lag
+			}
+		}
+		if sok {
+			lag.Offset = sinfo.SlaveReplOffset
+			lag.LinkStatus = sinfo.MasterLinkStatus
+			lag.LastIOSeconds = sinfo.MasterLastIOSeconds
+			lag.Syncing = sinfo.MasterSyncInProgress
+		}
+		lag.LagBytes = lag.MasterOffset - lag.Offset
+		if rate := report[i].Rate; mok && sok && lag.LagBytes <= 0 {
+			lag.LagSeconds = 0
+		} else if mok && sok && rate > 0 {
+			lag.LagSeconds = float64(lag.LagBytes) / rate
+		}
+
+		switch {
+		case !sok:
+			lag.Reason = fmt.Sprintf("replica is unreachable: %v", errs[node.ID])
+		case !mok:
+			lag.Reason = fmt.Sprintf("master is unreachable: %v", errs[node.SlaveOf])
+		case node.HasFlag("fail") || node.HasFlag("pfail"):
+			lag.Reason = "replica is failing"
+		case lag.LinkStatus != "up":
+			lag.Reason = "link is " + lag.LinkStatus
+		case lag.Syncing:
+			lag.Reason = "full sync is in progress"
+		case lag.LastAckSeconds < 0:
+			lag.Reason = "master does not list replica"
+		case lag.LagBytes > opt.MaxBytes:
+			lag.Reason = fmt.Sprintf("%d bytes behind", lag.LagBytes)
+		case lag.LagSeconds > float64(opt.MaxSeconds):
+			lag.Reason = fmt.Sprintf("about %.1fs behind", lag.LagSeconds)
+		case lag.LastIOSeconds > opt.MaxSeconds:
+			lag.Reason = fmt.Sprintf("no I/O from master for %ds", lag.LastIOSeconds)
+		case lag.LastAckSeconds > opt.MaxSeconds:
+			lag.Reason = fmt.Sprintf("no ack for %ds", lag.LastAckSeconds)
+		default:
+			lag.Safe = true
+		}
+		report[i].Replicas = append(report[i].Replicas, lag)
+	}
+	return report, nil
+}
+
+// readReplication returns 'INFO replication' of client
+func readReplication(ctx context.Context, client Client) (Replication, error) {
+	resp, err := client.Info(ctx, "replication")
+	if err != nil {
+		return Replication{}, err
+	}
+	return ParseReplication(resp)
+}
+
+// findReplicaState returns slaveN of node, slave of the same port is taken when master sees other IP such as behind NAT
+func findReplicaState(slaves []ReplicaState, node ClusterNode) (ReplicaState, bool) {
+	var byPort []ReplicaState
+	for _, slave := range slaves {
+		if slave.Port != int(node.Port) {
+			continue
+		}
+		if slave.IP == node.IP {
+			return slave, true
+		}
+		byPort = append(byPort, slave)
+	}
+	if len(byPort) == 1 {
+		return byPort[0], true
+	}
+	return ReplicaState{}, false
+}
@@ -0,0 +1,82 @@
+package rcc_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kizkoh/rcc/rcc"
+	"github.com/kizkoh/rcc/rcc/rcctest"
+)
+
+func TestReplicationReportLagSeconds(t *testing.T) {
+	id := "a000000000000000000000000000000000000000"
+	cluster, err := rcctest.NewCluster(
+		rcctest.Spec{ID: id, Slots: []rcc.Slot{{Start: 0, End: 16383}}},
+		rcctest.Spec{SlaveOf: id},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cluster.Close()
+	master, slave := cluster.Servers[0], cluster.Servers[1]
+	manager := rcc.NewManager(rcc.DefaultClientOptions)
+	defer manager.Close()
+	ctx := context.Background()
+	nodes, err := rcc.ClusterNodesContext(ctx, manager.Client(master.Addr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opt := rcc.DefaultLagOptions
+	opt.Interval = 200 * time.Millisecond
+	replica := func() (rcc.MasterReplication, rcc.ReplicaLag) {
+		t.Helper()
+		report, err := rcc.ReplicationReport(ctx, manager, nodes, opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(report) != 1 || len(report[0].Replicas) != 1 {
+			t.Fatalf("ReplicationReport() = %+v, want master of a replica", report)
+		}
+		return report[0], report[0].Replicas[0]
+	}
+
+	// master writes while report is taken, slave stays 1000 bytes behind
+	slave.SetReplication(1000, 0, false)
+	writer := rcc.NewClient(master.Addr, rcc.DefaultClientOptions)
+	defer writer.Close()
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			case <-time.After(time.Millisecond):
+			}
+			if _, err := writer.(rcc.Commander).Do(ctx, "set", fmt.Sprintf("key:%d", i), "value"); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	m, lag := replica()
+	close(done)
+	wg.Wait()
+	if m.Rate <= 0 || lag.LagBytes <= 0 || lag.LagSeconds != float64(lag.LagBytes)/m.Rate {
+		t.Errorf("lag of replica of master writing %.0fB/s = %dB/%gs, want bytes over rate", m.Rate, lag.LagBytes, lag.LagSeconds)
+	}
+
+	// idle master can not time lag of slave behind it
+	if m, lag := replica(); m.Rate != 0 || lag.LagBytes != 1000 || lag.LagSeconds != -1 {
+		t.Errorf("lag of replica of idle master = %dB/%gs at %.0fB/s, want 1000B of unknown seconds", lag.LagBytes, lag.LagSeconds, m.Rate)
+	}
+	slave.SetReplication(0, 0, false)
+	if _, lag := replica(); lag.LagBytes != 0 || lag.LagSeconds != 0 || !lag.Safe {
+		t.Errorf("lag of replica caught up = %dB/%gs safe %v, want 0", lag.LagBytes, lag.LagSeconds, lag.Safe)
+	}
+}
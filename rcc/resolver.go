@@ -0,0 +1,248 @@
+package rcc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Resolver looks up hostnames of IP address, *net.Resolver satisfies Resolver
+type Resolver interface {
+	LookupAddr(ctx context.Context, addr string) (names []string, err error)
+}
+
+// HostResolver looks up IP addresses of hostname, *net.Resolver satisfies HostResolver
+type HostResolver interface {
+	LookupIPAddr(ctx context.Context, host string) (addrs []net.IPAddr, err error)
+}
+
+var defaultResolver = NewCachedResolver(net.DefaultResolver, 2*time.Second)
+
+// DefaultResolver is resolver used by ClusterNodes to look up hostnames, nil disables reverse lookup
+var DefaultResolver Resolver = defaultResolver
+
+// DefaultHostResolver is resolver used to look up IP addresses of hostname given as node address
+var DefaultHostResolver HostResolver = defaultResolver
+
+// ResolveConcurrency is max number of concurrent lookups in ResolveHosts
+var ResolveConcurrency = 16
+
+// CachedResolver is resolver caching results of another resolver, a lookup is bounded by timeout
+//
+// LookupIPAddr is supported when another resolver is also HostResolver.
+type CachedResolver struct {
+	resolver Resolver
+	timeout  time.Duration
+
+	mu    sync.Mutex
+	cache map[string]resolved
+	hosts map[string]resolvedHost
+}
+
+type resolved struct {
+	names []string
+	err   error
+}
+
+type resolvedHost struct {
+	addrs []net.IPAddr
+	err   error
+}
+
+// NewCachedResolver returns resolver caching results of resolver, timeout 0 means no timeout
+func NewCachedResolver(resolver Resolver, timeout time.Duration) *CachedResolver {
+	return &CachedResolver{
+		resolver: resolver,
+		timeout:  timeout,
+		cache:    make(map[string]resolved),
+		hosts:    make(map[string]resolvedHost),
+	}
+}
+
+// LookupAddr returns cached hostnames of addr, failed lookup is also cached
+func (r *CachedResolver) LookupAddr(ctx context.Context, addr string) (names []string, err error) {
+	r.mu.Lock()
+	res, ok := r.cache[addr]
+	r.mu.Unlock()
+	if ok {
+		return res.names, res.err
+	}
+
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+	names, err = r.resolver.LookupAddr(ctx, addr)
+	if ctx.Err() == context.Canceled {
+		// canceled by caller, the next lookup may succeed
+		return names, err
+	}
+
+	r.mu.Lock()
+	r.cache[addr] = resolved{names: names, err: err}
+	r.mu.Unlock()
+	return names, err
+}
+
+// LookupIPAddr returns cached IP addresses of host, failed lookup is also cached
+func (r *CachedResolver) LookupIPAddr(ctx context.Context, host string) (addrs []net.IPAddr, err error) {
+	r.mu.Lock()
+	res, ok := r.hosts[host]
+	r.mu.Unlock()
+	if ok {
+		return res.addrs, res.err
+	}
+
+	resolver, ok := r.resolver.(HostResolver)
+	if !ok {
+		err = errors.New("Resolver does not support hostname lookup")
+		err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+		return nil, err
+	}
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+	addrs, err = resolver.LookupIPAddr(ctx, host)
+	if ctx.Err() == context.Canceled {
+		return addrs, err
+	}
+
+	r.mu.Lock()
+	r.hosts[host] = resolvedHost{addrs: addrs, err: err}
+	r.mu.Unlock()
+	return addrs, err
+}
+
+// ResolveHosts fill Host of nodes with hostname of IP concurrently, Host is IP when lookup fails
+func ResolveHosts(ctx context.Context, resolver Resolver, nodes []ClusterNode) {
+	for i := range nodes {
+		nodes[i].Host = nodes[i].IP
+	}
+	if resolver == nil {
+		return
+	}
+
+	var ips []string
+	hosts := make(map[string]string)
+	for _, node := range nodes {
+		if _, ok := hosts[node.IP]; !ok && node.IP != "" {
+			hosts[node.IP] = node.IP
+			ips = append(ips, node.IP)
+		}
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, ResolveConcurrency)
+	for _, ip := range ips {
+		wg.Add(1)
+		go func(ip string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			names, err := resolver.LookupAddr(ctx, ip)
+			if err != nil || len(names) == 0 {
+				return
+			}
+			mu.Lock()
+			hosts[ip] = names[0]
+			mu.Unlock()
+		}(ip)
+	}
+	wg.Wait()
+
+	for i := range nodes {
+		if host, ok := hosts[nodes[i].IP]; ok {
+			nodes[i].Host = host
+		}
+	}
+}
+
+// ResolveAddr returns every HOST:PORT of hostname in addr, A and AAAA records are both returned
+//
+// addr having IP address is returned as it is.
+func ResolveAddr(addr string) (addrs []string, err error) {
+	return ResolveAddrContext(context.Background(), addr)
+}
+
+// ResolveAddrContext is ResolveAddr bounded by ctx
+func ResolveAddrContext(ctx context.Context, addr string) (addrs []string, err error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+		return nil, err
+	}
+	if host == "" || net.ParseIP(host) != nil {
+		return []string{addr}, nil
+	}
+
+	ips, err := DefaultHostResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for _, ip := range ips {
+		a := net.JoinHostPort(ip.String(), port)
+		if !seen[a] {
+			seen[a] = true
+			addrs = append(addrs, a)
+		}
+	}
+	if len(addrs) == 0 {
+		err = errors.Wrap(ErrHostNotFound, fmt.Sprintf("%v-%v failed: %s", App.Name, App.Version, host))
+		return nil, err
+	}
+	return addrs, nil
+}
+
+// ResolveNode returns HOST:PORT of node named by addr, an error is returned when hostname maps to several nodes
+//
+// When hostname has several addresses, every address is asked its node ID and addresses of same node are accepted.
+func ResolveNode(manager *Manager, addr string) (nodeAddr string, err error) {
+	return ResolveNodeContext(context.Background(), manager, addr)
+}
+
+// ResolveNodeContext is ResolveNode bounded by ctx
+func ResolveNodeContext(ctx context.Context, manager *Manager, addr string) (nodeAddr string, err error) {
+	addrs, err := ResolveAddrContext(ctx, addr)
+	if err != nil {
+		return "", err
+	}
+	if len(addrs) == 1 {
+		return addrs[0], nil
+	}
+
+	nodes := make(map[string][]string)
+	var first string
+	var lastErr error
+	for _, a := range addrs {
+		id, err := nodeID(ctx, manager.Client(a))
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if len(nodes) == 0 {
+			first = a
+		}
+		nodes[id] = append(nodes[id], a)
+	}
+	switch len(nodes) {
+	case 0:
+		return "", lastErr
+	case 1:
+		return first, nil
+	}
+
+	err = &AmbiguousHostError{Addr: addr, Nodes: nodes}
+	err = errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+	return "", err
+}
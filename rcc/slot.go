@@ -0,0 +1,156 @@
+package rcc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// SlotCount is number of hash slots in redis cluster
+const SlotCount = 16384
+
+// KeySlot returns hash slot of key, only hash tag is hashed when key has non-empty '{...}'
+func KeySlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(crc16(key) % SlotCount)
+}
+
+// SlotOwners returns ID of master owning every slot, uncovered slot is empty
+func SlotOwners(cluster []ClusterNode) []string {
+	owners := make([]string, SlotCount)
+	for _, node := range cluster {
+		if node.Slave {
+			continue
+		}
+		for _, slot := range node.Slots {
+			if slot.From != "" || slot.To != "" {
+				continue
+			}
+			for i := slot.Start; i <= slot.End && i < SlotCount; i++ {
+				owners[i] = node.ID
+			}
+		}
+	}
+	return owners
+}
+
+// OpenSlots returns importing and migrating slots seen by every healthy master in cluster, keyed by slot
+//
+// Only node itself shows its open slots in 'CLUSTER NODES', so every master is asked.
+// Open slots of other masters are returned with first error.
+func OpenSlots(ctx context.Context, manager *Manager, cluster []ClusterNode) (open map[int][]Slot, err error) {
+	open = make(map[int][]Slot)
+	for _, master := range cluster {
+		if master.Slave || master.HasFlag("fail") || master.HasFlag("noaddr") {
+			continue
+		}
+		nodes, nerr := ClusterNodesContext(ctx, manager.NodeClient(master))
+		if nerr != nil {
+			if err == nil {
+				err = nerr
+			}
+			continue
+		}
+		for _, node := range nodes {
+			if !node.HasFlag("myself") {
+				continue
+			}
+			for _, slot := range node.Slots {
+				if slot.From != "" || slot.To != "" {
+					open[int(slot.Start)] = append(open[int(slot.Start)], slot)
+				}
+			}
+		}
+	}
+	return open, err
+}
+
+// NodeRef is node referred by ID and address
+type NodeRef struct {
+	ID   string `json:"id"`
+	Addr string `json:"addr"`
+}
+
+// MigrateBatch is number of keys moved by one MIGRATE
+var MigrateBatch = 100
+
+// MigrateTimeout is timeout of one MIGRATE
+var MigrateTimeout = 60 * time.Second
+
+// MigrateSlot moves slot and its keys from master src to master dst as redis-cli --cluster reshard does
+//
+// MIGRATE authenticates to dst with credentials of source client. New owner is set on dst and src first,
+// then on every other master seen by dst so that slot does not wait for gossip to move.
+// Slot is left importing and migrating when it fails, run it again or fix it with CLUSTER SETSLOT STABLE.
+func MigrateSlot(ctx context.Context, manager *Manager, slot int, src NodeRef, dst NodeRef) error {
+	srcClient, dstClient := manager.Client(src.Addr), manager.Client(dst.Addr)
+	host, port, err := net.SplitHostPort(dst.Addr)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+	}
+
+	if err := dstClient.ClusterSetSlot(ctx, slot, "importing", src.ID); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("%v-%v failed: %s", App.Name, App.Version, dst.Addr))
+	}
+	if err := srcClient.ClusterSetSlot(ctx, slot, "migrating", dst.ID); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("%v-%v failed: %s", App.Name, App.Version, src.Addr))
+	}
+	for {
+		keys, err := srcClient.ClusterGetKeysInSlot(ctx, slot, MigrateBatch)
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("%v-%v failed: %s", App.Name, App.Version, src.Addr))
+		}
+		if len(keys) == 0 {
+			break
+		}
+		if err := srcClient.Migrate(ctx, host, port, keys, MigrateTimeout); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("%v-%v failed: %s", App.Name, App.Version, src.Addr))
+		}
+	}
+
+	resp, err := dstClient.ClusterNodes(ctx)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("%v-%v failed: %s", App.Name, App.Version, dst.Addr))
+	}
+	cluster, err := ParseClusterNodes(resp)
+	if err != nil {
+		return err
+	}
+	// destination takes slot first, so that clients redirected by source find slot there
+	addrs := []string{dst.Addr, src.Addr}
+	for _, node := range cluster {
+		if node.Slave || node.ID == src.ID || node.ID == dst.ID || node.HasFlag("noaddr") || node.HasFlag("fail") || node.HasFlag("handshake") {
+			continue
+		}
+		addrs = append(addrs, node.Addr())
+	}
+	for _, addr := range addrs {
+		if err := manager.Client(addr).ClusterSetSlot(ctx, slot, "node", dst.ID); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("%v-%v failed: %s", App.Name, App.Version, addr))
+		}
+	}
+	return nil
+}
+
+// crc16 is CRC16-CCITT (XMODEM) used by redis cluster key hashing
+func crc16(s string) (crc uint16) {
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,104 @@
+package rcc_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/go-redis/redis"
+
+	"github.com/kizkoh/rcc/rcc"
+	"github.com/kizkoh/rcc/rcc/rcctest"
+)
+
+func TestMigrateSlotAuth(t *testing.T) {
+	tests := []struct {
+		name     string
+		user     string
+		password string
+		goRedis  bool // client is go-redis client adapted by NewRedisClient
+	}{
+		{name: "no auth"},
+		{name: "auth", password: "secret"},
+		{name: "auth2", user: "admin", password: "secret"},
+		{name: "auth of go-redis client", password: "secret", goRedis: true},
+		{name: "auth2 of go-redis client", user: "admin", password: "secret", goRedis: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster, err := rcctest.NewEvenCluster(3, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer cluster.Close()
+			for _, s := range cluster.Servers {
+				s.User, s.Password = tt.user, tt.password
+			}
+			src, dst := cluster.Servers[0], cluster.Servers[1]
+			slot := 0
+			for i := 0; i < 250; i++ {
+				key := fmt.Sprintf("{%s}%d", "06S", i)
+				slot = rcc.KeySlot(key)
+				src.Set(key, "v")
+			}
+			if owner := cluster.Owner(slot); owner != src.ID {
+				t.Fatalf("slot %d is owned by %s, want %s", slot, owner, src.ID)
+			}
+
+			opt := rcc.DefaultClientOptions
+			opt.User, opt.Password = tt.user, tt.password
+			manager := rcc.NewManager(opt)
+			if tt.goRedis {
+				manager = rcc.NewManagerFunc(func(addr string) rcc.Client {
+					return rcc.NewRedisClient(redis.NewClient(&redis.Options{Addr: addr, Password: tt.password}), tt.user)
+				})
+			}
+			defer manager.Close()
+			err = rcc.MigrateSlot(context.Background(), manager, slot, rcc.NodeRef{ID: src.ID, Addr: src.Addr}, rcc.NodeRef{ID: dst.ID, Addr: dst.Addr})
+			if err != nil {
+				t.Fatalf("MigrateSlot() error = %v", err)
+			}
+			if owner := cluster.Owner(slot); owner != dst.ID {
+				t.Errorf("slot %d is owned by %s, want %s", slot, owner, dst.ID)
+			}
+			for node, want := range map[*rcctest.Server]int64{src: 0, dst: 250} {
+				n, err := manager.Client(node.Addr).ClusterCountKeysInSlot(context.Background(), slot)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if n != want {
+					t.Errorf("%s has %d keys in slot %d, want %d", node.Addr, n, slot, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMigrateSlotWrongPassword(t *testing.T) {
+	cluster, err := rcctest.NewEvenCluster(2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cluster.Close()
+	src, dst := cluster.Servers[0], cluster.Servers[1]
+	src.Password, dst.Password = "secret", "other"
+	src.Set("{06S}0", "v")
+	slot := rcc.KeySlot("{06S}0")
+
+	// every node is reached with its own password, MIGRATE sends password of source to destination
+	passwords := map[string]string{src.Addr: src.Password, dst.Addr: dst.Password}
+	manager := rcc.NewManagerFunc(func(addr string) rcc.Client {
+		opt := rcc.DefaultClientOptions
+		opt.Password = passwords[addr]
+		return rcc.NewClient(addr, opt)
+	})
+	defer manager.Close()
+	err = rcc.MigrateSlot(context.Background(), manager, slot, rcc.NodeRef{ID: src.ID, Addr: src.Addr}, rcc.NodeRef{ID: dst.ID, Addr: dst.Addr})
+	if err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+		t.Fatalf("MigrateSlot() error = %v, want WRONGPASS", err)
+	}
+	if owner := cluster.Owner(slot); owner != src.ID {
+		t.Errorf("slot %d is owned by %s, want %s", slot, owner, src.ID)
+	}
+}
@@ -0,0 +1,224 @@
+package rcc
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ClusterStats is key statistics of cluster
+type ClusterStats struct {
+	Slots   []int64      `json:"slots"` // keys per slot, -1 for slot not counted
+	Shards  []ShardStats `json:"shards"`
+	Balance Balance      `json:"balance"`
+}
+
+// ShardStats is key statistics of master and slots owned by it
+type ShardStats struct {
+	ID       string     `json:"id"`
+	Addr     string     `json:"addr"`
+	Host     string     `json:"host"`
+	Flags    []string   `json:"flags"`
+	Slots    int        `json:"slots"` // counted slots
+	SlotKeys []SlotKeys `json:"slot_keys"`
+	NodeStats
+}
+
+// SlotKeys is number of keys in slot
+type SlotKeys struct {
+	Slot int   `json:"slot"`
+	Keys int64 `json:"keys"`
+}
+
+// NodeStats is keys and memory of node in 'INFO'
+type NodeStats struct {
+	Keys       uint64 `json:"keys"`
+	Expires    uint64 `json:"expires"`
+	UsedMemory uint64 `json:"used_memory"`
+}
+
+// SlotKeysSum returns sum of keys counted in slots
+func (shard ShardStats) SlotKeysSum() (sum int64) {
+	for _, slot := range shard.SlotKeys {
+		sum += slot.Keys
+	}
+	return sum
+}
+
+// Ranking returns slots of shard sorted by keys in descending order
+func (shard ShardStats) Ranking() []SlotKeys {
+	ranking := append([]SlotKeys(nil), shard.SlotKeys...)
+	sort.SliceStable(ranking, func(i, j int) bool {
+		return ranking[i].Keys > ranking[j].Keys
+	})
+	return ranking
+}
+
+// CollectStats returns key counts of every slot and statistics of every healthy master in cluster
+//
+// Statistics of other shards are returned with first error, interrupted collection returns error of ctx.
+func CollectStats(ctx context.Context, manager *Manager, cluster []ClusterNode, opt CountOptions) (stats *ClusterStats, err error) {
+	counts, err := CountKeysInSlots(ctx, manager, cluster, opt)
+	stats = &ClusterStats{Slots: counts, Shards: []ShardStats{}}
+	for _, node := range cluster {
+		if node.Slave || node.HasFlag("fail") || node.HasFlag("noaddr") {
+			continue
+		}
+		shard := NewShardStats(node, counts)
+		if shard.Slots == 0 {
+			continue
+		}
+		var nerr error
+		if shard.NodeStats, nerr = CollectNodeStats(ctx, manager.NodeClient(node)); nerr != nil && err == nil {
+			err = nerr
+		}
+		stats.Shards = append(stats.Shards, shard)
+	}
+	stats.Balance = NewBalance(stats.Shards)
+	return stats, err
+}
+
+// NewShardStats returns shard statistics of master from key counts of slots, slots not counted are skipped
+func NewShardStats(master ClusterNode, counts []int64) ShardStats {
+	shard := ShardStats{
+		ID:       master.ID,
+		Addr:     master.Addr(),
+		Host:     master.Host,
+		Flags:    master.Flags,
+		SlotKeys: []SlotKeys{},
+	}
+	for _, slot := range master.Slots {
+		if slot.From != "" || slot.To != "" {
+			continue
+		}
+		for i := slot.Start; i <= slot.End && i < SlotCount; i++ {
+			if counts[i] < 0 {
+				continue
+			}
+			shard.SlotKeys = append(shard.SlotKeys, SlotKeys{Slot: int(i), Keys: counts[i]})
+		}
+	}
+	shard.Slots = len(shard.SlotKeys)
+	return shard
+}
+
+// CollectNodeStats returns keys, expires of every database and used memory of node
+func CollectNodeStats(ctx context.Context, client Client) (stats NodeStats, err error) {
+	resp, err := client.Info(ctx, "keyspace")
+	if err != nil {
+		return stats, errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+	}
+	for key, value := range ParseInfo(resp) {
+		if !strings.HasPrefix(key, "db") {
+			continue
+		}
+		keyspace, err := ParseKeyspace(value)
+		if err != nil {
+			return stats, errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+		}
+		stats.Keys += keyspace.Keys
+		stats.Expires += keyspace.Expires
+	}
+
+	resp, err = client.Info(ctx, "memory")
+	if err != nil {
+		return stats, errors.Wrap(err, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+	}
+	if value, ok := ParseInfo(resp)["used_memory"]; ok {
+		stats.UsedMemory, err = strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			err = errors.Wrap(&ParseError{Line: "used_memory:" + value, Err: err}, fmt.Sprintf("%v-%v failed: ", App.Name, App.Version))
+			return stats, err
+		}
+	}
+	return stats, nil
+}
+
+// CountOptions is options of CountKeysInSlots
+type CountOptions struct {
+	Batch   int // COUNTKEYSINSLOT pipelined in one round trip, DefaultCountOptions.Batch when zero
+	Workers int // shards counted concurrently, DefaultCountOptions.Workers when zero
+}
+
+// DefaultCountOptions is default options of CountKeysInSlots
+var DefaultCountOptions = CountOptions{
+	Batch:   1000,
+	Workers: 8,
+}
+
+// CountKeysInSlots returns number of keys of every slot counted at masters in cluster
+//
+// Slot not owned by any healthy master in cluster, or not counted because of error, is -1.
+// Counts of other shards are returned with first error.
+func CountKeysInSlots(ctx context.Context, manager *Manager, cluster []ClusterNode, opt CountOptions) (counts []int64, err error) {
+	if opt.Batch <= 0 {
+		opt.Batch = DefaultCountOptions.Batch
+	}
+	if opt.Workers <= 0 {
+		opt.Workers = DefaultCountOptions.Workers
+	}
+	counts = make([]int64, SlotCount)
+	for i := range counts {
+		counts[i] = -1
+	}
+
+	shards := make(chan ClusterNode)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < opt.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for node := range shards {
+				if serr := countShard(ctx, manager.NodeClient(node), node, opt.Batch, counts); serr != nil {
+					mu.Lock()
+					if err == nil {
+						err = serr
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, node := range cluster {
+		if node.Slave || node.HasFlag("fail") || node.HasFlag("noaddr") {
+			continue
+		}
+		shards <- node
+	}
+	close(shards)
+	wg.Wait()
+	return counts, err
+}
+
+// countShard counts keys of slots owned by master node in pipelined batches, each worker writes its own slots
+func countShard(ctx context.Context, client Client, node ClusterNode, batch int, counts []int64) error {
+	var slots []int
+	for _, slot := range node.Slots {
+		if slot.From != "" || slot.To != "" {
+			continue
+		}
+		for i := slot.Start; i <= slot.End && i < SlotCount; i++ {
+			slots = append(slots, int(i))
+		}
+	}
+	for start := 0; start < len(slots); start += batch {
+		end := start + batch
+		if end > len(slots) {
+			end = len(slots)
+		}
+		val, err := client.ClusterCountKeysInSlots(ctx, slots[start:end])
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("%v-%v failed: %s", App.Name, App.Version, node.Addr()))
+		}
+		for i, count := range val {
+			counts[slots[start+i]] = count
+		}
+	}
+	return nil
+}